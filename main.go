@@ -5,17 +5,44 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"os/signal"
+	"path"
+	"regexp"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/dave/scrapy/scraper"
+	"github.com/dave/scrapy/scraper/canonical"
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/getter/cachegetter"
+	"github.com/dave/scrapy/scraper/getter/simgetter"
+	"github.com/dave/scrapy/scraper/getter/warcgetter"
 	"github.com/dave/scrapy/scraper/getter/webgetter"
+	"github.com/dave/scrapy/scraper/graph"
+	"github.com/dave/scrapy/scraper/linkcheck"
+	"github.com/dave/scrapy/scraper/logger"
 	"github.com/dave/scrapy/scraper/logger/consolelogger"
+	"github.com/dave/scrapy/scraper/logger/jsonlogger"
+	"github.com/dave/scrapy/scraper/logger/metricslogger"
+	"github.com/dave/scrapy/scraper/logger/multilogger"
+	"github.com/dave/scrapy/scraper/parser"
+	"github.com/dave/scrapy/scraper/parser/cssparser"
 	"github.com/dave/scrapy/scraper/parser/htmlparser"
+	"github.com/dave/scrapy/scraper/parser/textparser"
+	"github.com/dave/scrapy/scraper/parser/xmlparser"
+	"github.com/dave/scrapy/scraper/queuer"
 	"github.com/dave/scrapy/scraper/queuer/concurrentqueuer"
+	"github.com/dave/scrapy/scraper/queuer/diskqueuer"
+	"github.com/dave/scrapy/scraper/retry"
+	"github.com/dave/scrapy/scraper/scope"
 )
 
 func main() {
@@ -24,26 +51,316 @@ func main() {
 		url             string
 		length, workers int
 		timeout         int
+		depth           int
+		kinds           string
+		nofollow        bool
+		hostWorkers     int
+		hostDelay       int
+		robots          bool
+		agent           string
+		record, replay  string
+		warc            string
+		warcSize        int
+		frontier        string
+		resume          bool
+		stripTracking   bool
+		sortQuery       bool
+		queryAllow      string
+		trailingSlash   string
+		sitemaps        bool
+		headers         list
+		cookies         list
+		cookieJar       bool
+		proxy           string
+		insecure        bool
+		maxConns        int
+		auth            string
+		credentialHosts string
+		retries         int
+		retryBackoff    int
+		maxBody         int
+		format          string
+		metricsAddr     string
+		log             string
+		graph           string
+		checkLinks      bool
+		checkExternal   bool
+		headFirst       bool
+		maxLength       int
+		cache           string
+		seeds           string
+		scope           string
+		subdomains      bool
+		pathPrefixes    list
+		includes        list
+		excludes        list
+		excludeQuery    string
+		schemes         string
 	}{}
 
-	flag.StringVar(&config.url, "url", "https://monzo.com", "The start page")
+	flag.StringVar(&config.url, "url", "https://monzo.com", "The start page (if there are no other seed urls)")
+	flag.StringVar(&config.seeds, "seeds", "", "Read seed urls from this file, one per line")
+	flag.StringVar(&config.scope, "scope", "", "Read the scope rules from this JSON file (by default the hosts of the seed urls are crawled)")
+	flag.BoolVar(&config.subdomains, "subdomains", false, "Also crawl the subdomains of the seed hosts (and the apex domain for www hosts)")
+	flag.Var(&config.pathPrefixes, "path-prefix", "Only crawl urls on the seed hosts with this path prefix (can be repeated)")
+	flag.Var(&config.includes, "include", "Also crawl urls matching this regular expression (can be repeated)")
+	flag.Var(&config.excludes, "exclude", "Don't crawl urls matching this regular expression (can be repeated)")
+	flag.StringVar(&config.excludeQuery, "exclude-query", "", "Don't crawl urls with these query parameters, comma separated")
+	flag.StringVar(&config.schemes, "schemes", "", "Only crawl urls with these schemes, comma separated (default http,https)")
 	flag.IntVar(&config.length, "length", 1000, "Length of the queue")
 	flag.IntVar(&config.workers, "workers", 5, "Number of concurrent workers")
 	flag.IntVar(&config.timeout, "timeout", 10000, "Request timeout in ms")
+	flag.IntVar(&config.depth, "depth", -1, "Max number of links to follow from the seed urls, 0 to only get the seeds (-1 for no limit)")
+	flag.BoolVar(&config.nofollow, "nofollow", false, "Don't follow rel=nofollow links, or links on pages with meta robots nofollow")
+	flag.StringVar(&config.kinds, "kinds", "a", "Kinds of link to follow, comma separated: a, area, link, img, script, iframe, form, refresh, css or all")
+	flag.IntVar(&config.hostWorkers, "host-workers", 0, "Max number of concurrent workers for each host (0 for no limit)")
+	flag.IntVar(&config.hostDelay, "host-delay", 0, "Min delay between requests to the same host in ms")
+	flag.BoolVar(&config.robots, "robots", false, "Obey robots.txt rules")
+	flag.StringVar(&config.agent, "user-agent", "scrapy", "The User-Agent header, also used to choose the robots.txt rules")
+	flag.StringVar(&config.record, "record", "", "Record all responses to this directory")
+	flag.StringVar(&config.replay, "replay", "", "Replay responses from this directory instead of using the network")
+	flag.StringVar(&config.cache, "cache", "", "Cache responses in this directory, and only download pages that have changed since the last run")
+	flag.StringVar(&config.warc, "warc", "", "Archive all responses as WARC files in this directory")
+	flag.IntVar(&config.warcSize, "warc-size", 1024, "Max size of each WARC file in MB")
+	flag.StringVar(&config.frontier, "frontier", "", "Save the state of the crawl to this file")
+	flag.BoolVar(&config.resume, "resume", false, "Resume the crawl saved in the -frontier file")
+	flag.BoolVar(&config.stripTracking, "strip-tracking", false, "Remove tracking query parameters (e.g. utm_source, fbclid) from urls")
+	flag.BoolVar(&config.sortQuery, "sort-query", false, "Sort the query parameters of urls")
+	flag.StringVar(&config.queryAllow, "query-allow", "", "Remove all query parameters from urls except these, comma separated")
+	flag.StringVar(&config.trailingSlash, "trailing-slash", "strip", "What to do with trailing slashes in urls: keep, strip or add")
+	flag.BoolVar(&config.sitemaps, "sitemaps", false, "Queue the urls in the sitemaps listed in robots.txt and /sitemap.xml, and report the differences with the crawl")
+	flag.Var(&config.headers, "header", "An extra `header` to send with requests to the -credential-hosts, e.g. \"X-Foo: bar\" (can be repeated)")
+	flag.Var(&config.cookies, "cookie", "A `cookie` to send with requests to the -credential-hosts, e.g. \"session=abc\" (can be repeated)")
+	flag.BoolVar(&config.cookieJar, "cookie-jar", false, "Store cookies set by the server and send them with later requests")
+	flag.StringVar(&config.proxy, "proxy", "", "Proxy url (by default the HTTP_PROXY and HTTPS_PROXY environment variables are used)")
+	flag.BoolVar(&config.insecure, "insecure", false, "Skip TLS certificate verification")
+	flag.IntVar(&config.maxConns, "max-conns", 0, "Max number of connections to each host (0 for no limit)")
+	flag.StringVar(&config.auth, "auth", "", "Basic auth credentials as user:password, sent to the -credential-hosts")
+	flag.StringVar(&config.credentialHosts, "credential-hosts", "", "Only send -header, -cookie and -auth to these hosts, comma separated (default the hosts of the seed urls)")
+	flag.IntVar(&config.retries, "retries", 0, "Max number of retries for network errors and 429, 502, 503 and 504 responses")
+	flag.IntVar(&config.retryBackoff, "retry-backoff", 500, "Delay before the first retry in ms, doubled for each later retry")
+	flag.IntVar(&config.maxBody, "max-body", 0, "Max size of each response body in KB, longer bodies are truncated (0 for no limit)")
+	flag.StringVar(&config.format, "format", "console", "Output format: console, or json for one JSON object per event")
+	flag.StringVar(&config.metricsAddr, "metrics-addr", "", "Serve Prometheus metrics at /metrics on this address - e.g. :9090")
+	flag.StringVar(&config.graph, "graph", "", "Write the links between pages to this file, as Graphviz DOT (.dot), GraphML (.graphml) or CSV (.csv)")
+	flag.BoolVar(&config.checkLinks, "check-links", false, "Report broken links with the pages that link to them, and exit with code 1 if there are any")
+	flag.BoolVar(&config.checkExternal, "check-external", false, "Check the status of links to other hosts with HEAD requests, without crawling them")
+	flag.BoolVar(&config.headFirst, "head-first", false, "Send a HEAD request first, and only download bodies that will be parsed")
+	flag.IntVar(&config.maxLength, "max-length", 0, "With -head-first, don't download bodies with a Content-Length longer than this in KB (0 for no limit)")
+	flag.StringVar(&config.log, "log", "", "Also write one JSON object per event to this file")
 	flag.Parse()
 
-	// If there is an anonymous command line argument, use it as the url
-	if arg := flag.Arg(0); arg != "" {
-		config.url = arg
+	// The seed urls are the anonymous command line arguments and the urls in the seeds file, or -url if there are none
+	raw := flag.Args()
+	if config.seeds != "" {
+		lines, err := readSeeds(config.seeds)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		raw = append(raw, lines...)
+	}
+	if len(raw) == 0 {
+		raw = []string{config.url}
 	}
 
-	// Make sure we can parse the URL
-	base, err := url.Parse(config.url)
-	if err != nil {
-		fmt.Println(err)
+	// Choose the rules used to convert urls to canonical form
+	rules := &canonical.Rules{
+		LowercaseHost:   true,
+		DropDefaultPort: true,
+		DropFragment:    true,
+		StripTracking:   config.stripTracking,
+		SortQuery:       config.sortQuery,
+	}
+	if config.queryAllow != "" {
+		rules.QueryAllowlist = split(config.queryAllow)
+	}
+	switch config.trailingSlash {
+	case "keep":
+		rules.TrailingSlash = canonical.KeepSlash
+	case "strip":
+		rules.TrailingSlash = canonical.StripSlash
+	case "add":
+		rules.TrailingSlash = canonical.AddSlash
+	default:
+		fmt.Println("trailing-slash must be keep, strip or add")
 		os.Exit(1)
 	}
 
+	// Choose the loggers
+	var loggers []logger.Interface
+	switch config.format {
+	case "console":
+		loggers = append(loggers, &consolelogger.Logger{})
+	case "json":
+		loggers = append(loggers, &jsonlogger.Logger{})
+	default:
+		fmt.Println("format must be console or json")
+		os.Exit(1)
+	}
+
+	// Write events to a log file if needed
+	var logFile *os.File
+	if config.log != "" {
+		f, err := os.Create(config.log)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		logFile = f
+		loggers = append(loggers, &jsonlogger.Logger{Writer: logFile})
+	}
+
+	// Serve metrics in the Prometheus text format
+	if config.metricsAddr != "" {
+		listener, err := net.Listen("tcp", config.metricsAddr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		metrics := &metricslogger.Logger{}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics)
+		go http.Serve(listener, mux)
+		loggers = append(loggers, metrics)
+	}
+
+	// Forward events to all the loggers, so a slow logger doesn't hold up the crawl
+	var log logger.Interface = loggers[0]
+	var multi *multilogger.Logger
+	if len(loggers) > 1 {
+		multi = &multilogger.Logger{Loggers: loggers}
+		log = multi
+	}
+
+	// Record the links between pages if needed, in a format chosen by the file extension
+	var links *graph.Graph
+	var writeGraph func(io.Writer) error
+	if config.graph != "" {
+		links = &graph.Graph{}
+		switch path.Ext(config.graph) {
+		case ".dot", ".gv":
+			writeGraph = links.WriteDOT
+		case ".graphml":
+			writeGraph = links.WriteGraphML
+		case ".csv":
+			writeGraph = links.WriteCSV
+		default:
+			fmt.Println("graph file must end in .dot, .graphml or .csv")
+			os.Exit(1)
+		}
+	}
+
+	// Record the links to each url and its status, to report broken links
+	var checker *linkcheck.Checker
+	if config.checkLinks {
+		checker = &linkcheck.Checker{}
+	}
+
+	// Make sure we can parse the seed urls, and find the hosts that will be crawled
+	var seeds, hosts, domains []string
+	for _, r := range raw {
+		if err := checkSeed(r); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		u, _ := url.Parse(r) // can't fail, the url was checked
+		u = rules.URL(u)
+		seeds = append(seeds, u.String())
+		hosts = append(hosts, u.Host)
+		domains = append(domains, strings.TrimPrefix(u.Hostname(), "www."))
+	}
+
+	// Choose the scope, from the config file or the hosts of the seed urls, and the flags
+	sc := &scope.Scope{}
+	if config.scope != "" {
+		if config.subdomains || len(config.pathPrefixes) > 0 {
+			fmt.Println("-subdomains and -path-prefix can't be used with -scope, add the rules to the scope file instead")
+			os.Exit(1)
+		}
+		c, err := scope.Load(config.scope)
+		if err == nil {
+			sc, err = c.Scope()
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	// Only links that none of the include rules accept are checked by -check-external, not urls excluded on purpose.
+	// Path prefixes only limit the pages crawled on the seed hosts, so they aren't used.
+	var crawled []scope.Rule
+	if sc.Include == nil {
+		site := scope.Host(hosts...)
+		if config.subdomains {
+			site = scope.Subdomains(domains...)
+		}
+		crawled = []scope.Rule{site}
+		if len(config.pathPrefixes) > 0 {
+			site = scope.All(site, scope.PathPrefix(config.pathPrefixes...))
+		}
+		sc.Include = []scope.Rule{site}
+	} else {
+		crawled = append(crawled, sc.Include...)
+	}
+	for _, expr := range config.includes {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		sc.Include = append(sc.Include, scope.Regexp(re))
+		crawled = append(crawled, scope.Regexp(re))
+	}
+	external := scope.Not(scope.Any(crawled...)).Match
+	for _, expr := range config.excludes {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		sc.Exclude = append(sc.Exclude, scope.Regexp(re))
+	}
+	if config.excludeQuery != "" {
+		sc.Exclude = append(sc.Exclude, scope.Query(split(config.excludeQuery)...))
+	}
+	if config.schemes != "" {
+		sc.Schemes = split(config.schemes)
+	}
+
+	// Choose the kinds of link to follow
+	var kinds []parser.Kind
+	if config.kinds == "all" {
+		kinds = parser.Kinds
+	} else {
+		for _, k := range split(config.kinds) {
+			if !validKind(parser.Kind(k)) {
+				fmt.Printf("unknown kind %q\n", k)
+				os.Exit(1)
+			}
+			kinds = append(kinds, parser.Kind(k))
+		}
+	}
+
+	// Create the queuer, which can save the state of the crawl so it can be resumed
+	var q queuer.Interface = &concurrentqueuer.Queuer{
+		Length:      config.length,
+		Workers:     config.workers,
+		HostWorkers: config.hostWorkers,
+		HostDelay:   time.Duration(config.hostDelay) * time.Millisecond,
+		Canonical:   rules,
+	}
+	var frontier *diskqueuer.Queuer
+	if config.frontier != "" {
+		var err error
+		frontier, err = diskqueuer.Open(config.frontier, q, rules, config.resume)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		q = frontier
+	}
+
 	// Create a context that will be cancelled on Ctrl+C
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
@@ -56,26 +373,284 @@ func main() {
 
 		// clear the "^C" emitted to the console
 		// TODO: Is this cross-platform?
-		fmt.Print("\r")
+		if config.format == "console" {
+			fmt.Print("\r")
+		}
+
+		// Stop saving the state of the crawl, so the items that are interrupted are still pending when resuming
+		if frontier != nil {
+			frontier.Close()
+		}
 
 		// Call the context cancellation function
 		cancel()
 	}()
 
+	// Create the getter, which can record responses or replay previously recorded responses
+	web := &webgetter.Getter{
+		Robots:          config.robots,
+		UserAgent:       config.agent,
+		Insecure:        config.insecure,
+		MaxConnsPerHost: config.maxConns,
+		MaxBodyBytes:    int64(config.maxBody) << 10,
+		CredentialHosts: hosts,
+	}
+	if config.credentialHosts != "" {
+		web.CredentialHosts = split(config.credentialHosts)
+	}
+	if len(config.headers) > 0 {
+		web.Header = http.Header{}
+		for _, h := range config.headers {
+			i := strings.Index(h, ":")
+			if i == -1 {
+				fmt.Println("header must be in the form \"Name: value\"")
+				os.Exit(1)
+			}
+			web.Header.Add(strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:]))
+		}
+	}
+	for _, c := range config.cookies {
+		i := strings.Index(c, "=")
+		if i == -1 {
+			fmt.Println("cookie must be in the form name=value")
+			os.Exit(1)
+		}
+		web.Cookies = append(web.Cookies, &http.Cookie{Name: strings.TrimSpace(c[:i]), Value: strings.TrimSpace(c[i+1:])})
+	}
+	if config.cookieJar {
+		// The jar can't fail to be created without options
+		web.Jar, _ = cookiejar.New(nil)
+	}
+	if config.proxy != "" {
+		var err error
+		web.Proxy, err = url.Parse(config.proxy)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if config.auth != "" {
+		i := strings.Index(config.auth, ":")
+		if i == -1 {
+			fmt.Println("auth must be in the form user:password")
+			os.Exit(1)
+		}
+		web.Username, web.Password = config.auth[:i], config.auth[i+1:]
+	}
+	var g getter.Interface = web
+	if config.cache != "" {
+		g = &cachegetter.Getter{Getter: g, Dir: config.cache}
+	}
+	switch {
+	case config.replay != "":
+		g = &simgetter.Replayer{Dir: config.replay}
+	case config.record != "":
+		g = &simgetter.Recorder{Getter: g, Dir: config.record}
+	}
+
+	// Archive the responses if needed
+	var archive *warcgetter.Getter
+	if config.warc != "" {
+		archive = &warcgetter.Getter{Getter: g, Dir: config.warc, MaxSize: int64(config.warcSize) << 20}
+		g = archive
+	}
+
+	// Retry transient failures if needed
+	var policy *retry.Policy
+	if config.retries > 0 {
+		policy = &retry.Policy{
+			Attempts:   config.retries + 1,
+			Backoff:    time.Duration(config.retryBackoff) * time.Millisecond,
+			MaxBackoff: time.Minute,
+			Jitter:     0.5,
+		}
+	}
+
+	// Only accept the url if it is in scope - e.g. on the host of one of the seed urls
+	include := sc.Match
+
+	// Sitemaps and feeds are parsed by the xml parser
+	feeds := &xmlparser.Parser{Include: include, Canonical: rules}
+
 	// Create a scraper
 	s := &scraper.State{
-		Timeout: time.Duration(config.timeout) * time.Millisecond,
-		Getter:  &webgetter.Getter{},
+		Timeout:   time.Duration(config.timeout) * time.Millisecond,
+		Sitemaps:  config.sitemaps,
+		Canonical: rules,
+		Include:   include,
+		Getter:    g,
 		Parser: &htmlparser.Parser{
-			Include: func(u *url.URL) bool {
-				// Only accept the url if the host matches the host of the base page - e.g. some domain.
-				return u != nil && u.Host == base.Host
-			},
+			Include:   include,
+			Kinds:     kinds,
+			Nofollow:  config.nofollow,
+			Canonical: rules,
+		},
+		Parsers: map[string]parser.Interface{
+			"text/css":             &cssparser.Parser{Include: include, Canonical: rules},
+			"text/plain":           &textparser.Parser{Include: include, Canonical: rules},
+			"text/xml":             feeds,
+			"application/xml":      feeds,
+			"application/rss+xml":  feeds,
+			"application/atom+xml": feeds,
 		},
-		Queuer: &concurrentqueuer.Queuer{Length: config.length, Workers: config.workers},
-		Logger: &consolelogger.Logger{},
+		Queuer: q,
+		Logger: log,
+		Retry:  policy,
+		Graph:  links,
+
+		Checker:       checker,
+		CheckExternal: config.checkExternal,
+		External:      external,
+	}
+
+	// Limit the number of links followed from the seed urls
+	if config.depth >= 0 {
+		s.MaxDepth = &config.depth
+	}
+
+	// Only download the bodies of documents that have a parser
+	if config.headFirst {
+		web.HeadFirst = true
+		web.MaxLength = int64(config.maxLength) << 10
+		web.MediaTypes = []string{"text/html", "application/xhtml+xml"}
+		for mediaType := range s.Parsers {
+			web.MediaTypes = append(web.MediaTypes, mediaType)
+		}
 	}
 
 	// Start the scraper
-	s.Start(ctx, base.String())
+	s.Start(ctx, seeds...)
+
+	// Report events that the loggers didn't receive
+	if multi != nil {
+		for i, n := range multi.Dropped() {
+			if n > 0 {
+				fmt.Fprintf(os.Stderr, "logger %d dropped %d events\n", i, n)
+			}
+		}
+		for i, n := range multi.Panics() {
+			if n > 0 {
+				fmt.Fprintf(os.Stderr, "logger %d panicked on %d events\n", i, n)
+			}
+		}
+	}
+
+	// Write the graph
+	if links != nil {
+		f, err := os.Create(config.graph)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := writeGraph(f); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := f.Close(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	// Close the log file
+	if logFile != nil {
+		if err := logFile.Close(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	// Close the current WARC file
+	if archive != nil {
+		if err := archive.Close(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	// Close the frontier file
+	if frontier != nil {
+		if err := frontier.Close(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	// Report the broken links (to stderr for json, so stdout is still valid), and fail if there are any
+	if checker != nil {
+		out := os.Stdout
+		if config.format == "json" {
+			out = os.Stderr
+		}
+		fmt.Fprintln(out, "")
+		if err := checker.WriteReport(out); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if len(checker.Broken()) > 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+// list is a flag.Value that collects the values of a flag that can be repeated
+type list []string
+
+func (l *list) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *list) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// readSeeds reads the urls from a seeds file, one per line. Blank lines and lines starting with # are ignored.
+func readSeeds(path string) ([]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var urls []string
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := checkSeed(line); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, i+1, err)
+		}
+		urls = append(urls, line)
+	}
+	return urls, nil
+}
+
+// checkSeed returns an error if a seed url isn't an absolute http or https url - e.g. "example.com" without a scheme
+func checkSeed(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("seed url %q must start with http:// or https:// and have a host", raw)
+	}
+	return nil
+}
+
+// validKind returns true if the kind is one of the kinds of reference found in HTML documents
+func validKind(kind parser.Kind) bool {
+	for _, k := range parser.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// split splits a comma separated flag value, trimming spaces
+func split(value string) (values []string) {
+	for _, v := range strings.Split(value, ",") {
+		values = append(values, strings.TrimSpace(v))
+	}
+	return values
 }
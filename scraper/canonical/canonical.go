@@ -0,0 +1,171 @@
+// Package canonical resolves url references and converts urls to a canonical form, in order to reduce duplicates
+package canonical
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Rules configures how urls are converted to canonical form. A nil *Rules leaves urls unchanged.
+type Rules struct {
+	LowercaseHost   bool     // Convert the host to lower case
+	DropDefaultPort bool     // Remove the port if it's the default for the scheme - e.g. :443 for https
+	DropFragment    bool     // Remove the #fragment
+	StripTracking   bool     // Remove tracking query parameters - e.g. utm_source, fbclid
+	QueryAllowlist  []string // If not nil, remove all query parameters that aren't in this list
+	SortQuery       bool     // Sort the query parameters by key
+	TrailingSlash   Slash    // What to do with trailing slashes in the path
+}
+
+// Slash is a trailing slash policy
+type Slash int
+
+// Trailing slash policies. The root path is always "/".
+const (
+	KeepSlash  Slash = iota // Leave trailing slashes as they are
+	StripSlash              // Remove trailing slashes - e.g. "/a/" becomes "/a"
+	AddSlash                // Add a trailing slash to paths where the last segment has no extension - e.g. "/a" becomes "/a/"
+)
+
+// Default is the default set of rules
+var Default = &Rules{
+	LowercaseHost:   true,
+	DropDefaultPort: true,
+	DropFragment:    true,
+	TrailingSlash:   StripSlash,
+}
+
+// tracking is the list of tracking query parameters removed by StripTracking, in addition to "utm_*"
+var tracking = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+}
+
+// defaultPorts is the default port for each scheme
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Resolve parses the reference and resolves it against the base url as described in RFC 3986 section 5
+func Resolve(base *url.URL, ref string) (*url.URL, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	return base.ResolveReference(u), nil
+}
+
+// URL returns a copy of the url in canonical form
+func (r *Rules) URL(in *url.URL) *url.URL {
+	u := *in
+	if r == nil {
+		return &u
+	}
+
+	if r.LowercaseHost {
+		u.Host = strings.ToLower(u.Host)
+	}
+
+	if r.DropDefaultPort && u.Port() != "" && u.Port() == defaultPorts[u.Scheme] {
+		u.Host = strings.TrimSuffix(u.Host, ":"+u.Port())
+	}
+
+	if r.DropFragment {
+		u.Fragment = ""
+	}
+
+	if u.RawQuery != "" && (r.StripTracking || r.QueryAllowlist != nil || r.SortQuery) {
+		u.RawQuery = r.query(u.RawQuery)
+		u.ForceQuery = false
+	}
+
+	// The empty path is equivalent to "/" for urls with a host (RFC 3986 section 6.2.3)
+	if u.Host != "" && u.Path == "" {
+		u.Path = "/"
+		u.RawPath = ""
+	}
+
+	switch r.TrailingSlash {
+	case StripSlash:
+		if u.Path != "/" && strings.HasSuffix(u.Path, "/") {
+			u.Path = strings.TrimRight(u.Path, "/")
+			u.RawPath = strings.TrimRight(u.RawPath, "/")
+			if u.Path == "" {
+				u.Path = "/"
+				u.RawPath = ""
+			}
+		}
+	case AddSlash:
+		if !strings.HasSuffix(u.Path, "/") && path.Ext(u.Path) == "" {
+			u.Path += "/"
+			if u.RawPath != "" {
+				u.RawPath += "/"
+			}
+		}
+	}
+
+	return &u
+}
+
+// String parses the url and returns it in canonical form. If the url can't be parsed, it is returned unchanged.
+func (r *Rules) String(raw string) string {
+	if r == nil {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return r.URL(u).String()
+}
+
+// query applies the query parameter rules to a raw query. The encoding and order of the parameters is preserved
+// unless SortQuery is set.
+func (r *Rules) query(raw string) string {
+	var allow map[string]bool
+	if r.QueryAllowlist != nil {
+		allow = map[string]bool{}
+		for _, k := range r.QueryAllowlist {
+			allow[k] = true
+		}
+	}
+
+	type param struct{ key, raw string }
+	var params []param
+	for _, p := range strings.Split(raw, "&") {
+		if p == "" {
+			continue
+		}
+		key := p
+		if i := strings.Index(p, "="); i > -1 {
+			key = p[:i]
+		}
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if r.StripTracking && (strings.HasPrefix(key, "utm_") || tracking[key]) {
+			continue
+		}
+		if allow != nil && !allow[key] {
+			continue
+		}
+		params = append(params, param{key: key, raw: p})
+	}
+
+	if r.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool { return params[i].key < params[j].key })
+	}
+
+	out := make([]string, len(params))
+	for i, p := range params {
+		out[i] = p.raw
+	}
+	return strings.Join(out, "&")
+}
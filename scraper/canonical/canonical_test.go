@@ -0,0 +1,162 @@
+package canonical
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name, base, ref, expected string
+	}{
+		{
+			name:     "absolute",
+			base:     "https://a/b",
+			ref:      "http://c/d",
+			expected: "http://c/d",
+		},
+		{
+			name:     "relative to file",
+			base:     "https://a/b/c",
+			ref:      "d",
+			expected: "https://a/b/d",
+		},
+		{
+			name:     "relative to directory",
+			base:     "https://a/b/c/",
+			ref:      "d",
+			expected: "https://a/b/c/d",
+		},
+		{
+			name:     "dot segments",
+			base:     "https://a/b/c/d",
+			ref:      "./../../e",
+			expected: "https://a/e",
+		},
+		{
+			name:     "query only",
+			base:     "https://a/b?c=1",
+			ref:      "?c=2",
+			expected: "https://a/b?c=2",
+		},
+		{
+			name:     "scheme relative",
+			base:     "https://a/b",
+			ref:      "//c/d",
+			expected: "https://c/d",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			base, err := url.Parse(test.base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := Resolve(base, test.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.String() != test.expected {
+				t.Errorf("expected %s, but got %s", test.expected, u.String())
+			}
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name, url, expected string
+		rules               *Rules
+	}{
+		{
+			name:     "nil rules",
+			url:      "HTTPS://A:443/b/#c",
+			expected: "HTTPS://A:443/b/#c",
+		},
+		{
+			name:     "default",
+			rules:    Default,
+			url:      "HTTPS://A.com:443/B/#c",
+			expected: "https://a.com/B",
+		},
+		{
+			name:     "non default port",
+			rules:    Default,
+			url:      "http://a:443/b",
+			expected: "http://a:443/b",
+		},
+		{
+			name:     "ipv6 default port",
+			rules:    Default,
+			url:      "http://[::1]:80/b",
+			expected: "http://[::1]/b",
+		},
+		{
+			name:     "empty path",
+			rules:    &Rules{},
+			url:      "https://a",
+			expected: "https://a/",
+		},
+		{
+			name:     "strip slash keeps root",
+			rules:    &Rules{TrailingSlash: StripSlash},
+			url:      "https://a/",
+			expected: "https://a/",
+		},
+		{
+			name:     "add slash",
+			rules:    &Rules{TrailingSlash: AddSlash},
+			url:      "https://a/b",
+			expected: "https://a/b/",
+		},
+		{
+			name:     "add slash skips files",
+			rules:    &Rules{TrailingSlash: AddSlash},
+			url:      "https://a/b.html",
+			expected: "https://a/b.html",
+		},
+		{
+			name:     "strip tracking",
+			rules:    &Rules{StripTracking: true},
+			url:      "https://a/?b=1&utm_source=c&fbclid=d&e=%20",
+			expected: "https://a/?b=1&e=%20",
+		},
+		{
+			name:     "strip all tracking",
+			rules:    &Rules{StripTracking: true},
+			url:      "https://a/?utm_source=c",
+			expected: "https://a/",
+		},
+		{
+			name:     "allowlist",
+			rules:    &Rules{QueryAllowlist: []string{"page", "q"}},
+			url:      "https://a/?sort=1&q=2&page=3",
+			expected: "https://a/?q=2&page=3",
+		},
+		{
+			name:     "sort query",
+			rules:    &Rules{SortQuery: true},
+			url:      "https://a/?c=1&a=2&b=3&a=1",
+			expected: "https://a/?a=2&a=1&b=3&c=1",
+		},
+		{
+			name:     "keep fragment",
+			rules:    &Rules{},
+			url:      "https://a/b#c",
+			expected: "https://a/b#c",
+		},
+		{
+			name:     "parse error",
+			rules:    Default,
+			url:      ":",
+			expected: ":",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if out := test.rules.String(test.url); out != test.expected {
+				t.Errorf("expected %s, but got %s", test.expected, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+// Package cachegetter defines a getter.Interface that wraps another getter and caches responses on disk, so repeat
+// crawls only download pages that have changed
+package cachegetter
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+)
+
+// Getter is a getter.Interface that wraps another getter and caches successful responses with an ETag or
+// Last-Modified header in a directory. Cached responses that are still fresh (according to Cache-Control max-age or
+// Expires) are returned without a request. Otherwise a conditional request is sent with If-None-Match and
+// If-Modified-Since (using getter.Options), and the cached body is returned if the server responds 304 Not
+// Modified. Result.Cache reports how each result was found.
+type Getter struct {
+	Getter getter.Interface // The getter to cache
+	Dir    string           // The directory to store the cache in (created if it doesn't exist)
+}
+
+// entry is the stored form of a response
+type entry struct {
+	URL       string            `json:"url"`
+	Final     string            `json:"final,omitempty"` // The final url, if the request was redirected
+	Redirects []getter.Redirect `json:"redirects,omitempty"`
+	Code      int               `json:"code"`
+	Header    http.Header       `json:"header,omitempty"`
+	Body      []byte            `json:"body,omitempty"`
+	Time      time.Time         `json:"time"` // When the response was received or last revalidated
+}
+
+// Get returns a channel. Later it sends the response, and closes the channel.
+func (g *Getter) Get(ctx context.Context, url string, options getter.Options) chan getter.Result {
+	out := make(chan getter.Result)
+	go func() {
+		// Make sure we close the channel.
+		defer close(out)
+
+		// HEAD requests have no body to cache
+		if options.Head {
+			out <- <-g.Getter.Get(ctx, url, options)
+			return
+		}
+
+		// A missing or unreadable entry is a cache miss
+		cached, _ := g.load(url)
+
+		// Fresh responses don't need a request
+		if cached != nil && time.Since(cached.Time) < lifetime(cached.Header) {
+			out <- cached.result(getter.CacheHit)
+			return
+		}
+
+		// Ask the server to only send the body if it has changed
+		if cached != nil {
+			header := http.Header{}
+			for key, values := range options.Header {
+				header[key] = values
+			}
+			if etag := cached.Header.Get("ETag"); etag != "" {
+				header.Set("If-None-Match", etag)
+			}
+			if modified := cached.Header.Get("Last-Modified"); modified != "" {
+				header.Set("If-Modified-Since", modified)
+			}
+			options.Header = header
+		}
+
+		// The wrapped getter respects cancellation, so we can wait for the result
+		result := <-g.Getter.Get(ctx, url, options)
+		if result.Err != nil {
+			out <- result
+			return
+		}
+
+		// The cached response hasn't changed, so update its headers (e.g. a new max-age) and return it
+		if result.Code == http.StatusNotModified && cached != nil {
+			if result.Body != nil {
+				result.Body.Close()
+			}
+			for key, values := range result.Header {
+				cached.Header[key] = values
+			}
+			cached.Time = time.Now()
+			cached.Redirects = result.Redirects
+			cached.Final = ""
+			if result.URL != url {
+				cached.Final = result.URL
+			}
+			if err := g.save(cached); err != nil {
+				out <- getter.Result{Err: err}
+				return
+			}
+			out <- cached.result(getter.CacheRevalidated)
+			return
+		}
+
+		result.Cache = getter.CacheMiss
+		if !cacheable(result) {
+			out <- result
+			return
+		}
+
+		// Read the whole body so it can be stored, and replace it with a buffer for the caller
+		b, err := ioutil.ReadAll(result.Body)
+		result.Body.Close()
+		if err != nil {
+			out <- getter.Result{Err: err}
+			return
+		}
+		result.Body = getter.Buffer(b)
+
+		// Truncated bodies aren't stored, so the full body is downloaded if the limit is changed
+		if !result.Truncated {
+			e := &entry{URL: url, Redirects: result.Redirects, Code: result.Code, Header: result.Header, Body: b, Time: time.Now()}
+			if result.URL != url {
+				e.Final = result.URL
+			}
+			if err := g.save(e); err != nil {
+				result.Body.Close()
+				out <- getter.Result{Err: err}
+				return
+			}
+		}
+		out <- result
+	}()
+	return out
+}
+
+// result returns the cached response as a result
+func (e *entry) result(cache string) getter.Result {
+	final := e.URL
+	if e.Final != "" {
+		final = e.Final
+	}
+	// The content type is found from the cached headers
+	mediaType, charset := getter.ContentType(e.Header.Get("Content-Type"))
+	return getter.Result{
+		URL:       final,
+		Redirects: e.Redirects,
+		Code:      e.Code,
+		Header:    e.Header,
+		Body:      getter.Buffer(e.Body),
+		MediaType: mediaType,
+		Charset:   charset,
+		Cache:     cache,
+	}
+}
+
+// cacheable returns true if the result should be stored: successful responses with a body and an ETag or
+// Last-Modified header, which haven't asked not to be stored
+func cacheable(r getter.Result) bool {
+	if r.Code != http.StatusOK || r.Body == nil || r.Skipped {
+		return false
+	}
+	if directive(r.Header, "no-store") {
+		return false
+	}
+	return r.Header.Get("ETag") != "" || r.Header.Get("Last-Modified") != ""
+}
+
+// lifetime returns how long a response is fresh for, from the Cache-Control max-age or Expires headers (zero if it
+// must always be revalidated)
+func lifetime(header http.Header) time.Duration {
+	if directive(header, "no-cache") {
+		return 0
+	}
+	for _, d := range strings.Split(header.Get("Cache-Control"), ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if strings.HasPrefix(d, "max-age=") {
+			seconds, err := strconv.Atoi(strings.TrimPrefix(d, "max-age="))
+			if err != nil {
+				return 0
+			}
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		date, err := http.ParseTime(header.Get("Date"))
+		if err != nil {
+			return 0
+		}
+		return expires.Sub(date)
+	}
+	return 0
+}
+
+// directive returns true if the Cache-Control header contains the directive
+func directive(header http.Header, name string) bool {
+	for _, d := range strings.Split(header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(d), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// load reads the cached entry for a url
+func (g *Getter) load(url string) (*entry, error) {
+	b, err := ioutil.ReadFile(filename(g.Dir, url))
+	if err != nil {
+		return nil, err
+	}
+	var e entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, err
+	}
+	if e.Header == nil {
+		e.Header = http.Header{}
+	}
+	return &e, nil
+}
+
+// save writes the entry to the directory
+func (g *Getter) save(e *entry) error {
+	if err := os.MkdirAll(g.Dir, 0777); err != nil {
+		return err
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename(g.Dir, e.URL), b, 0666)
+}
+
+// filename returns the filename of the entry for a url
+func filename(dir, url string) string {
+	sum := sha1.Sum([]byte(url))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,115 @@
+package cachegetter
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/getter/webgetter"
+)
+
+func TestGetter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cachegetter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Each page has a version in its ETag and body, which is changed by the tests
+	versions := map[string]string{"/etag": "1", "/fresh": "1", "/none": "1"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version := versions[r.URL.Path]
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=3600")
+		case "/none":
+			w.Write([]byte("body " + version))
+			return
+		}
+		w.Header().Set("ETag", `"`+version+`"`)
+		if r.Header.Get("If-None-Match") == `"`+version+`"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body " + version))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name, path, version, cache, body string
+	}{
+		{name: "first", path: "/etag", cache: getter.CacheMiss, body: "body 1"},
+		{name: "not modified", path: "/etag", cache: getter.CacheRevalidated, body: "body 1"},
+		{name: "modified", path: "/etag", version: "2", cache: getter.CacheMiss, body: "body 2"},
+		{name: "modified again", path: "/etag", cache: getter.CacheRevalidated, body: "body 2"},
+		{name: "fresh first", path: "/fresh", cache: getter.CacheMiss, body: "body 1"},
+		{name: "fresh", path: "/fresh", version: "2", cache: getter.CacheHit, body: "body 1"},
+		{name: "no validators", path: "/none", cache: getter.CacheMiss, body: "body 1"},
+		{name: "no validators again", path: "/none", version: "2", cache: getter.CacheMiss, body: "body 2"},
+	}
+	g := &Getter{Getter: &webgetter.Getter{}, Dir: dir}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.version != "" {
+				versions[test.path] = test.version
+			}
+			r := <-g.Get(context.Background(), ts.URL+test.path, getter.Options{})
+			if r.Err != nil {
+				t.Fatal(r.Err)
+			}
+			b, err := ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Code != 200 {
+				t.Errorf("unexpected code %d", r.Code)
+			}
+			if r.Cache != test.cache {
+				t.Errorf("unexpected cache %q, expected %q", r.Cache, test.cache)
+			}
+			if string(b) != test.body {
+				t.Errorf("unexpected body %q, expected %q", string(b), test.body)
+			}
+		})
+	}
+}
+
+func TestGetter_skipped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cachegetter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("ETag", `"1"`)
+		if r.Header.Get("If-None-Match") == `"1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("zip"))
+	}))
+	defer ts.Close()
+
+	// The body isn't downloaded, so the empty body mustn't be cached and returned for a later GET
+	g := &Getter{Getter: &webgetter.Getter{HeadFirst: true, MediaTypes: []string{"text/html"}}, Dir: dir}
+	for i := 0; i < 2; i++ {
+		r := <-g.Get(context.Background(), ts.URL, getter.Options{})
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		r.Body.Close()
+		if !r.Skipped {
+			t.Error("expected the body to be skipped")
+		}
+		if r.Cache != getter.CacheMiss {
+			t.Errorf("unexpected cache %q, expected %q", r.Cache, getter.CacheMiss)
+		}
+	}
+}
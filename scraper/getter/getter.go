@@ -2,19 +2,127 @@
 package getter
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
 )
 
 // Interface is used to request results by URL
 type Interface interface {
-	Get(ctx context.Context, url string) chan Result // Get returns a channel. Later it sends the response, and closes the channel.
+	Get(ctx context.Context, url string, options Options) chan Result // Get returns a channel. Later it sends the response, and closes the channel.
+}
+
+// Options changes how a url is requested
+type Options struct {
+	Head      bool        // Only get the status and headers, with a HEAD request (getters that don't support it return the full response)
+	Header    http.Header // Extra headers to send with the request - e.g. If-None-Match
+	Anonymous bool        // Don't send the configured credentials, cookies and headers - e.g. when checking links to other sites
 }
 
 // Result is the result of a Get
 type Result struct {
-	Code int           // The http status code
-	Body io.ReadCloser // The body - remember the caller of Get is responsible for closing this.
-	HTML bool          // Did the content-type header indicates HTML?
-	Err  error         // Any error (all other fields will be zero if Err != nil)
+	URL       string        // The final url, after following redirects
+	Redirects []Redirect    // The redirects that were followed to get to the final url, in order
+	Code      int           // The http status code
+	Header    http.Header   // The response headers
+	Proto     string        // The protocol of the response - e.g. "HTTP/1.1" (empty if not known)
+	Request   *http.Request // The request that was sent for the final url, with its headers (nil if not known)
+	Body      io.ReadCloser // The body - remember the caller of Get is responsible for closing this.
+	MediaType string        // The media type from the Content-Type header, in lower case without parameters - e.g. "text/html"
+	Charset   string        // The charset parameter from the Content-Type header, in lower case (empty if not specified)
+	Truncated bool          // Was the body truncated by a size limit?
+	Skipped   bool          // Was the body skipped, because only the status and headers were requested? (the body is empty)
+	Cache     string        // How the result was found in a cache - CacheHit, CacheRevalidated or CacheMiss (empty if not cached)
+	Err       error         // Any error (all other fields except Redirects will be zero if Err != nil)
+}
+
+// Cache results, reported in Result.Cache by caching getters
+const (
+	CacheHit         = "hit"         // The cached response was still fresh, so no request was sent
+	CacheRevalidated = "revalidated" // The server said the cached response was not modified
+	CacheMiss        = "miss"        // The response wasn't cached, or had changed
+)
+
+// Redirect is a hop in a chain of redirects
+type Redirect struct {
+	URL  string // The url that was redirected
+	Code int    // The http status code of the redirect response
+}
+
+// ErrDisallowed is returned in Result.Err when the URL is disallowed by robots.txt
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// ErrRedirectLoop is returned in Result.Err when a redirect leads back to a url that was already visited
+var ErrRedirectLoop = errors.New("redirect loop")
+
+// ErrTooManyRedirects is returned in Result.Err when a redirect chain is longer than MaxRedirects
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// MaxRedirects is the max number of redirects that are followed
+const MaxRedirects = 10
+
+// ContentType returns the media type (in lower case, without parameters) and charset from a Content-Type header
+func ContentType(header string) (mediaType, charset string) {
+	mediaType, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		// Fall back to the part before any parameters
+		return strings.ToLower(strings.TrimSpace(strings.Split(header, ";")[0])), ""
+	}
+	return mediaType, strings.ToLower(params["charset"])
+}
+
+// LimitedReadCloser reads from R but stops after N bytes, like io.LimitedReader. Truncated is set if there was more
+// data after the limit.
+type LimitedReadCloser struct {
+	R         io.ReadCloser // The underlying body
+	N         int64         // Max number of bytes remaining
+	Truncated bool          // Was the body truncated?
+}
+
+// Read reads from the underlying body, returning io.EOF when the limit is reached
+func (l *LimitedReadCloser) Read(p []byte) (int, error) {
+	if l.N <= 0 {
+		// Read one more byte to find out if the body was truncated
+		if !l.Truncated {
+			var b [1]byte
+			if n, _ := io.ReadAtLeast(l.R, b[:], 1); n > 0 {
+				l.Truncated = true
+			}
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.N {
+		p = p[:l.N]
+	}
+	n, err := l.R.Read(p)
+	l.N -= int64(n)
+	return n, err
+}
+
+// Close closes the underlying body
+func (l *LimitedReadCloser) Close() error {
+	return l.R.Close()
+}
+
+// Limit reads up to n bytes of the body and closes it, and returns a buffer with the bytes that were read. Truncated
+// is true if the body was longer. Getters that limit the size of bodies use this, so Result.Truncated is known before
+// the result is sent.
+func Limit(body io.ReadCloser, n int64) (buffer io.ReadCloser, truncated bool, err error) {
+	l := &LimitedReadCloser{R: body, N: n}
+	b, err := ioutil.ReadAll(l)
+	body.Close()
+	if err != nil {
+		return nil, false, err
+	}
+	return Buffer(b), l.Truncated, nil
+}
+
+// Buffer returns a body that reads from b. Getters that read the whole body from another getter use this to replace it.
+func Buffer(b []byte) io.ReadCloser {
+	return ioutil.NopCloser(bytes.NewReader(b))
 }
@@ -0,0 +1,64 @@
+package getter
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestLimitedReadCloser(t *testing.T) {
+	tests := []struct {
+		name, body, expected string
+		limit                int64
+		truncated            bool
+	}{
+		{
+			name:     "shorter",
+			body:     "abc",
+			limit:    5,
+			expected: "abc",
+		},
+		{
+			name:     "exact",
+			body:     "abc",
+			limit:    3,
+			expected: "abc",
+		},
+		{
+			name:      "longer",
+			body:      "abcdef",
+			limit:     3,
+			expected:  "abc",
+			truncated: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := &LimitedReadCloser{R: ioutil.NopCloser(strings.NewReader(test.body)), N: test.limit}
+			b, err := ioutil.ReadAll(l)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, string(b))
+			}
+			if l.Truncated != test.truncated {
+				t.Errorf("expected truncated %v, got %v", test.truncated, l.Truncated)
+			}
+		})
+	}
+}
+
+func TestLimit(t *testing.T) {
+	body, truncated, err := Limit(ioutil.NopCloser(strings.NewReader("abcdef")), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "abc" || !truncated {
+		t.Errorf("expected %q to be truncated, got %q (truncated %v)", "abc", string(b), truncated)
+	}
+}
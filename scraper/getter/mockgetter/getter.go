@@ -4,7 +4,9 @@ package mockgetter
 import (
 	"bytes"
 	"context"
+	"io"
 	"io/ioutil"
+	"sync"
 	"time"
 
 	"github.com/dave/scrapy/scraper/getter"
@@ -12,19 +14,27 @@ import (
 
 // Getter is a getter.Interface that returns results mock results for use in tests
 type Getter struct {
-	Results map[string]Dummy // The results to return: url -> result
+	Results      map[string]Dummy // The results to return: url -> result
+	MaxBodyBytes int64            // Max number of bytes to read from each body (zero for no limit), as in webgetter
+	attempts     map[string]int   // Number of requests for each url
+	m            sync.Mutex       // Protects attempts
 }
 
 // Dummy contains information about the result
 type Dummy struct {
-	Body    string        // Contents of the body as a string
-	Code    int           // Response code
-	Latency time.Duration // Time to wait before returning
-	Err     error         // Error to return
+	Body      string            // Contents of the body as a string
+	Code      int               // Response code
+	Latency   time.Duration     // Time to wait before returning
+	Err       error             // Error to return
+	URL       string            // The final url, if the request was redirected
+	Redirects []getter.Redirect // The redirects that were followed
+	Before    []Dummy           // Results to return for the first requests for this url, before this result - e.g. to test retries
+	MediaType string            // Media type (default "text/html")
+	Skipped   bool              // The body was skipped, as by webgetter with HeadFirst
 }
 
 // Get returns a channel. Later it sends the response, and closes the channel.
-func (h *Getter) Get(ctx context.Context, url string) chan getter.Result {
+func (h *Getter) Get(ctx context.Context, url string, options getter.Options) chan getter.Result {
 	out := make(chan getter.Result)
 	go func() {
 		// Make sure we close the channel.
@@ -32,10 +42,16 @@ func (h *Getter) Get(ctx context.Context, url string) chan getter.Result {
 
 		// Look up the result in the mock results collection by url.
 		result, ok := h.Results[url]
+		if ok && len(result.Before) > 0 {
+			if attempt := h.attempt(url); attempt < len(result.Before) {
+				result = result.Before[attempt]
+			}
+		}
 
 		// If we don't have a result for this URL, return a 404 error.
 		if !ok {
 			out <- getter.Result{
+				URL:  url,
 				Code: 404,
 				Body: ioutil.NopCloser(bytes.NewBufferString("404 not found")),
 			}
@@ -56,7 +72,8 @@ func (h *Getter) Get(ctx context.Context, url string) chan getter.Result {
 		// Return an error if required
 		if result.Err != nil {
 			out <- getter.Result{
-				Err: result.Err,
+				Err:       result.Err,
+				Redirects: result.Redirects,
 			}
 			return
 		}
@@ -67,13 +84,47 @@ func (h *Getter) Get(ctx context.Context, url string) chan getter.Result {
 			code = result.Code
 		}
 
+		// If the final url isn't specified, the request wasn't redirected
+		final := url
+		if result.URL != "" {
+			final = result.URL
+		}
+
+		// If media type isn't specified, default to HTML
+		mediaType := "text/html"
+		if result.MediaType != "" {
+			mediaType = result.MediaType
+		}
+
+		var body io.ReadCloser = ioutil.NopCloser(bytes.NewBufferString(result.Body))
+		var truncated bool
+		if h.MaxBodyBytes > 0 {
+			// Reading from a string can't fail
+			body, truncated, _ = getter.Limit(body, h.MaxBodyBytes)
+		}
+
 		// Return the mock result
 		out <- getter.Result{
-			Code: code,
-			Body: ioutil.NopCloser(bytes.NewBufferString(result.Body)),
-			HTML: true,
+			URL:       final,
+			Redirects: result.Redirects,
+			Code:      code,
+			Body:      body,
+			MediaType: mediaType,
+			Truncated: truncated,
+			Skipped:   result.Skipped,
 		}
 	}()
 	return out
 
 }
+
+// attempt returns the number of previous requests for the url
+func (h *Getter) attempt(url string) int {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if h.attempts == nil {
+		h.attempts = map[string]int{}
+	}
+	h.attempts[url]++
+	return h.attempts[url] - 1
+}
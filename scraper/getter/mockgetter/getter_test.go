@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"testing"
 	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
 )
 
 // A quick test for mock getter
@@ -20,7 +22,7 @@ func TestGetter(t *testing.T) {
 			},
 		},
 	}
-	r := <-g.Get(context.Background(), "a")
+	r := <-g.Get(context.Background(), "a", getter.Options{})
 	b, err := ioutil.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
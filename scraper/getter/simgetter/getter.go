@@ -0,0 +1,191 @@
+// Package simgetter defines getter.Interface implementations that record results to a directory, and replay them with
+// the original latencies
+package simgetter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+)
+
+// Recorder is a getter.Interface that wraps another getter and records every result to a directory
+type Recorder struct {
+	Getter getter.Interface // The getter to record
+	Dir    string           // The directory to store the recordings in (created if it doesn't exist)
+}
+
+// Replayer is a getter.Interface that replays results recorded by Recorder
+type Replayer struct {
+	Dir string // The directory the recordings are stored in
+}
+
+// record is the stored form of a result
+type record struct {
+	URL       string            `json:"url"`
+	Final     string            `json:"final,omitempty"` // The final url, if the request was redirected
+	Redirects []getter.Redirect `json:"redirects,omitempty"`
+	Code      int               `json:"code,omitempty"`
+	Header    http.Header       `json:"header,omitempty"`
+	Body      []byte            `json:"body,omitempty"`
+	Truncated bool              `json:"truncated,omitempty"` // The body was truncated by a size limit
+	Skipped   bool              `json:"skipped,omitempty"`   // The body was skipped, because only the headers were requested
+	Err       string            `json:"err,omitempty"`
+	Latency   time.Duration     `json:"latency"`
+}
+
+// Get returns a channel. Later it sends the response, and closes the channel.
+func (r *Recorder) Get(ctx context.Context, url string, options getter.Options) chan getter.Result {
+	out := make(chan getter.Result)
+	go func() {
+		// Make sure we close the channel.
+		defer close(out)
+
+		start := time.Now()
+
+		// The wrapped getter respects cancellation, so we can wait for the result
+		result := <-r.Getter.Get(ctx, url, options)
+
+		rec := record{URL: url, Redirects: result.Redirects, Code: result.Code, Header: result.Header}
+		if result.URL != url {
+			rec.Final = result.URL
+		}
+
+		if result.Err != nil {
+			// Cancellation is not a property of the site, so don't record it
+			if ctx.Err() != nil {
+				out <- result
+				return
+			}
+			rec.Err = result.Err.Error()
+		} else if result.Body != nil {
+			// Read the whole body so it can be stored, and replace it with a buffer for the caller
+			b, err := ioutil.ReadAll(result.Body)
+			result.Body.Close()
+			if err != nil {
+				out <- getter.Result{Err: err}
+				return
+			}
+			rec.Body = b
+			result.Body = getter.Buffer(b)
+			rec.Truncated = result.Truncated
+			rec.Skipped = result.Skipped
+		}
+
+		rec.Latency = time.Since(start)
+
+		if err := r.save(rec); err != nil {
+			if result.Body != nil {
+				result.Body.Close()
+			}
+			out <- getter.Result{Err: err}
+			return
+		}
+
+		out <- result
+	}()
+	return out
+}
+
+// save writes the record to the directory
+func (r *Recorder) save(rec record) error {
+	if err := os.MkdirAll(r.Dir, 0777); err != nil {
+		return err
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename(r.Dir, rec.URL), b, 0666)
+}
+
+// Get returns a channel. Later it sends the response, and closes the channel.
+func (r *Replayer) Get(ctx context.Context, url string, options getter.Options) chan getter.Result {
+	out := make(chan getter.Result)
+	go func() {
+		// Make sure we close the channel.
+		defer close(out)
+
+		b, err := ioutil.ReadFile(filename(r.Dir, url))
+		if os.IsNotExist(err) {
+			// If we don't have a recording for this URL, return a 404 error.
+			out <- getter.Result{
+				URL:  url,
+				Code: 404,
+				Body: ioutil.NopCloser(bytes.NewBufferString("404 not found")),
+			}
+			return
+		}
+		if err != nil {
+			out <- getter.Result{Err: err}
+			return
+		}
+
+		var rec record
+		if err := json.Unmarshal(b, &rec); err != nil {
+			out <- getter.Result{Err: err}
+			return
+		}
+
+		// Wait for the original latency but respect cancellation
+		select {
+		case <-time.After(rec.Latency):
+			// great!
+		case <-ctx.Done():
+			out <- getter.Result{Err: ctx.Err()}
+			return
+		}
+
+		// Return the recorded error if there was one
+		if rec.Err != "" {
+			out <- getter.Result{Err: replayError(rec.Err), Redirects: rec.Redirects}
+			return
+		}
+
+		final := url
+		if rec.Final != "" {
+			final = rec.Final
+		}
+
+		// The content type is found from the recorded headers
+		mediaType, charset := getter.ContentType(rec.Header.Get("Content-Type"))
+
+		out <- getter.Result{
+			URL:       final,
+			Redirects: rec.Redirects,
+			Code:      rec.Code,
+			Header:    rec.Header,
+			Body:      getter.Buffer(rec.Body),
+			MediaType: mediaType,
+			Charset:   charset,
+			Truncated: rec.Truncated,
+			Skipped:   rec.Skipped,
+		}
+	}()
+	return out
+}
+
+// replayError converts a recorded error message back to an error, preserving sentinel errors
+func replayError(message string) error {
+	for _, err := range []error{getter.ErrDisallowed, getter.ErrRedirectLoop, getter.ErrTooManyRedirects} {
+		if message == err.Error() {
+			return err
+		}
+	}
+	return errors.New(message)
+}
+
+// filename returns the name of the file that stores the recording for a url
+func filename(dir, url string) string {
+	sum := sha1.Sum([]byte(url))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,89 @@
+package simgetter
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/getter/mockgetter"
+)
+
+func TestGetter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "simgetter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rec := &Recorder{
+		Dir: dir,
+		Getter: &mockgetter.Getter{
+			Results: map[string]mockgetter.Dummy{
+				"a": {Body: "a_body", Latency: 20 * time.Millisecond},
+				"b": {Err: errors.New("b_error")},
+				"c": {Err: getter.ErrDisallowed},
+			},
+		},
+	}
+
+	// Record the results
+	for _, url := range []string{"a", "b", "c"} {
+		r := <-rec.Get(context.Background(), url, getter.Options{})
+		if r.Body != nil {
+			r.Body.Close()
+		}
+	}
+
+	rep := &Replayer{Dir: dir}
+
+	tests := []struct {
+		url, body, err string
+		code           int
+		latency        time.Duration
+	}{
+		{url: "a", body: "a_body", code: 200, latency: 20 * time.Millisecond},
+		{url: "b", err: "b_error"},
+		{url: "c", err: getter.ErrDisallowed.Error()},
+		{url: "d", body: "404 not found", code: 404},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			start := time.Now()
+			r := <-rep.Get(context.Background(), test.url, getter.Options{})
+			if elapsed := time.Since(start); elapsed < test.latency {
+				t.Errorf("expected latency of at least %v, found %v", test.latency, elapsed)
+			}
+			var body string
+			if r.Body != nil {
+				b, err := ioutil.ReadAll(r.Body)
+				r.Body.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = string(b)
+			}
+			if body != test.body {
+				t.Errorf("expected body %q, found %q", test.body, body)
+			}
+			if r.Code != test.code {
+				t.Errorf("expected code %d, found %d", test.code, r.Code)
+			}
+			var errString string
+			if r.Err != nil {
+				errString = r.Err.Error()
+			}
+			if errString != test.err {
+				t.Errorf("expected error %q, found %q", test.err, errString)
+			}
+		})
+	}
+
+	// Sentinel errors should be preserved
+	if r := <-rep.Get(context.Background(), "c", getter.Options{}); r.Err != getter.ErrDisallowed {
+		t.Errorf("expected getter.ErrDisallowed, found %v", r.Err)
+	}
+}
@@ -0,0 +1,272 @@
+// Package warcgetter defines a getter.Interface that wraps another getter and archives every response in WARC format
+package warcgetter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+)
+
+// DefaultMaxSize is the default size at which a new WARC file is started (1 GB, as recommended by the WARC spec)
+const DefaultMaxSize = 1 << 30
+
+// Getter is a getter.Interface that wraps another getter and writes a WARC request and response record for every
+// successful result. Records are written to gzipped WARC files in Dir, starting a new file when the current file
+// exceeds MaxSize. Call Close when finished to close the current file.
+type Getter struct {
+	Getter  getter.Interface // The getter to archive
+	Dir     string           // The directory to write WARC files to (created if it doesn't exist)
+	Prefix  string           // Prefix for the WARC file names (default "scrapy")
+	MaxSize int64            // Start a new file when the current file reaches this size in bytes (default DefaultMaxSize)
+	m       sync.Mutex       // Protects the fields below
+	file    *os.File         // The current file (nil before the first record and after rotation)
+	written *countingWriter  // Counts the bytes written to the current file
+	count   int              // Number of files started
+	started time.Time        // Time the first file was started, used in file names
+}
+
+// Get returns a channel. Later it sends the response, and closes the channel.
+func (g *Getter) Get(ctx context.Context, url string, options getter.Options) chan getter.Result {
+	out := make(chan getter.Result)
+	go func() {
+		// Make sure we close the channel.
+		defer close(out)
+
+		// The wrapped getter respects cancellation, so we can wait for the result
+		result := <-g.Getter.Get(ctx, url, options)
+
+		// Errors and HEAD requests have no content to archive, including responses where the body was skipped
+		if result.Err != nil || options.Head || result.Skipped {
+			out <- result
+			return
+		}
+
+		// Read the whole body so it can be archived, and replace it with a buffer for the caller
+		var body []byte
+		if result.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(result.Body)
+			result.Body.Close()
+			if err != nil {
+				out <- getter.Result{Err: err}
+				return
+			}
+			result.Body = getter.Buffer(body)
+		}
+
+		// The content is archived against the final url if the request was redirected
+		target := url
+		if result.URL != "" {
+			target = result.URL
+		}
+
+		if err := g.archive(target, result, body); err != nil {
+			out <- getter.Result{Err: err}
+			return
+		}
+
+		out <- result
+	}()
+	return out
+}
+
+// Close closes the current WARC file
+func (g *Getter) Close() error {
+	g.m.Lock()
+	defer g.m.Unlock()
+	return g.closeFile()
+}
+
+// archive writes the request and response records for a result
+func (g *Getter) archive(rawurl string, result getter.Result, body []byte) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	date := time.Now().UTC()
+
+	// The response block is the status line and headers followed by the payload
+	proto := result.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	response := &bytes.Buffer{}
+	fmt.Fprintf(response, "%s %d %s\r\n", proto, result.Code, http.StatusText(result.Code))
+	if err := result.Header.Write(response); err != nil {
+		return err
+	}
+	response.WriteString("\r\n")
+	response.Write(body)
+
+	// The request block is the request that was sent, or is reconstructed from the url if the wrapped getter doesn't
+	// expose it
+	request := &bytes.Buffer{}
+	if req := result.Request; req != nil {
+		fmt.Fprintf(request, "%s %s %s\r\nHost: %s\r\n", req.Method, req.URL.RequestURI(), proto, req.URL.Host)
+		if err := req.Header.Write(request); err != nil {
+			return err
+		}
+		request.WriteString("\r\n")
+	} else {
+		fmt.Fprintf(request, "GET %s %s\r\nHost: %s\r\n\r\n", u.RequestURI(), proto, u.Host)
+	}
+
+	responseID, err := recordID()
+	if err != nil {
+		return err
+	}
+	requestID, err := recordID()
+	if err != nil {
+		return err
+	}
+
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	if err := g.ensureFile(); err != nil {
+		return err
+	}
+
+	headers := [][2]string{
+		{"WARC-Type", "response"},
+		{"WARC-Record-ID", responseID},
+		{"WARC-Date", date.Format(time.RFC3339)},
+		{"WARC-Target-URI", rawurl},
+		{"Content-Type", "application/http; msgtype=response"},
+		{"WARC-Payload-Digest", digest(body)},
+	}
+	if result.Truncated {
+		// The body was truncated by a size limit
+		headers = append(headers, [2]string{"WARC-Truncated", "length"})
+	}
+
+	if err := g.writeRecord(headers, response.Bytes()); err != nil {
+		return err
+	}
+
+	if err := g.writeRecord([][2]string{
+		{"WARC-Type", "request"},
+		{"WARC-Record-ID", requestID},
+		{"WARC-Date", date.Format(time.RFC3339)},
+		{"WARC-Target-URI", rawurl},
+		{"WARC-Concurrent-To", responseID},
+		{"Content-Type", "application/http; msgtype=request"},
+	}, request.Bytes()); err != nil {
+		return err
+	}
+
+	// Start a new file for the next record if this one is full
+	maxSize := g.MaxSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxSize
+	}
+	if g.written.n >= maxSize {
+		return g.closeFile()
+	}
+	return nil
+}
+
+// ensureFile opens a new WARC file and writes the warcinfo record if there is no current file
+func (g *Getter) ensureFile() error {
+	if g.file != nil {
+		return nil
+	}
+	if err := os.MkdirAll(g.Dir, 0777); err != nil {
+		return err
+	}
+	if g.count == 0 {
+		g.started = time.Now().UTC()
+	}
+	prefix := g.Prefix
+	if prefix == "" {
+		prefix = "scrapy"
+	}
+	name := fmt.Sprintf("%s-%s-%05d.warc.gz", prefix, g.started.Format("20060102150405"), g.count)
+	id, err := recordID()
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(g.Dir, name))
+	if err != nil {
+		return err
+	}
+	g.file = f
+	g.written = &countingWriter{w: f}
+	g.count++
+
+	return g.writeRecord([][2]string{
+		{"WARC-Type", "warcinfo"},
+		{"WARC-Record-ID", id},
+		{"WARC-Date", time.Now().UTC().Format(time.RFC3339)},
+		{"WARC-Filename", name},
+		{"Content-Type", "application/warc-fields"},
+	}, []byte("software: scrapy\r\nformat: WARC File Format 1.1\r\n"))
+}
+
+// closeFile closes the current file if there is one
+func (g *Getter) closeFile() error {
+	if g.file == nil {
+		return nil
+	}
+	err := g.file.Close()
+	g.file = nil
+	g.written = nil
+	return err
+}
+
+// writeRecord writes a record to the current file as a separate gzip member, so records can be read individually
+func (g *Getter) writeRecord(headers [][2]string, block []byte) error {
+	z := gzip.NewWriter(g.written)
+	fmt.Fprint(z, "WARC/1.1\r\n")
+	for _, h := range headers {
+		fmt.Fprintf(z, "%s: %s\r\n", h[0], h[1])
+	}
+	fmt.Fprintf(z, "WARC-Block-Digest: %s\r\n", digest(block))
+	fmt.Fprintf(z, "Content-Length: %d\r\n\r\n", len(block))
+	z.Write(block)
+	fmt.Fprint(z, "\r\n\r\n")
+	return z.Close()
+}
+
+// digest returns the WARC digest of the data - the base32 encoded SHA-1
+func digest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// recordID returns a new random record id
+func recordID() (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// countingWriter counts the bytes written to the underlying writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,162 @@
+package warcgetter
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/getter/mockgetter"
+	"github.com/dave/scrapy/scraper/getter/webgetter"
+)
+
+func TestGetter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warcgetter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g := &Getter{
+		Dir:     dir,
+		MaxSize: 1, // Every file will be rotated after the first pair of records
+		Getter: &mockgetter.Getter{
+			Results: map[string]mockgetter.Dummy{
+				"https://a/b?c": {Body: "a_body"},
+				"https://d":     {Body: "d_body"},
+				"https://e":     {Skipped: true},
+			},
+		},
+	}
+
+	for _, url := range []string{"https://a/b?c", "https://d"} {
+		r := <-g.Get(context.Background(), url, getter.Options{})
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(string(b), "_body") {
+			t.Errorf("unexpected body %q", string(b))
+		}
+	}
+	// The body of https://e was skipped, so it isn't archived in a third file
+	if r := <-g.Get(context.Background(), "https://e", getter.Options{}); r.Err != nil || !r.Skipped {
+		t.Fatalf("expected a skipped result, got %#v", r)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "scrapy-*.warc.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, found %d", len(files))
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := string(b)
+
+	expected := []string{
+		"WARC-Type: warcinfo\r\n",
+		"WARC-Type: response\r\n",
+		"WARC-Target-URI: https://a/b?c\r\n",
+		"HTTP/1.1 200 OK\r\n\r\na_body\r\n\r\n",
+		"WARC-Payload-Digest: sha1:KZ63AKDIGSQSWRMR24H7BSCMXQCTDRFG\r\n",
+		"WARC-Type: request\r\n",
+		"GET /b?c HTTP/1.1\r\nHost: a\r\n\r\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(contents, e) {
+			t.Errorf("expected WARC file to contain %q", e)
+		}
+	}
+	if strings.Contains(contents, "https://d") {
+		t.Error("expected second url to be written to the second file")
+	}
+}
+
+func TestGetter_request(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warcgetter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "a_body")
+	}))
+	defer ts.Close()
+
+	g := &Getter{
+		Dir: dir,
+		Getter: &webgetter.Getter{
+			UserAgent:       "test/1.0",
+			Header:          http.Header{"Accept": {"text/html"}},
+			CredentialHosts: []string{"127.0.0.1"},
+		},
+	}
+	r := <-g.Get(context.Background(), ts.URL+"/a", getter.Options{})
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	r.Body.Close()
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "scrapy-*.warc.gz"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected 1 file, found %d (%v)", len(files), err)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := string(b)
+
+	// The request record has the headers that were sent
+	expected := []string{
+		"GET /a HTTP/1.1\r\nHost: " + strings.TrimPrefix(ts.URL, "http://") + "\r\n",
+		"Accept: text/html\r\n",
+		"User-Agent: test/1.0\r\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(contents, e) {
+			t.Errorf("expected WARC file to contain %q", e)
+		}
+	}
+}
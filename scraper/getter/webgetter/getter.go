@@ -3,36 +3,100 @@ package webgetter
 
 import (
 	"context"
+	"crypto/tls"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dave/scrapy/scraper/getter"
 )
 
 // Getter is a getter.Interface that returns results real results by HTTP
 type Getter struct {
-	client http.Client // the http client to use
+	Robots          bool           // Obey robots.txt rules, including Crawl-delay. Disallowed urls return getter.ErrDisallowed.
+	RobotsRetry     time.Duration  // How long a server error for robots.txt disallows the host, before it is fetched again (default DefaultRobotsRetry)
+	UserAgent       string         // User-Agent header to send, also used to choose the robots.txt rules
+	Header          http.Header    // Extra headers to send with every request to CredentialHosts
+	Cookies         []*http.Cookie // Cookies to send with every request to CredentialHosts
+	CredentialHosts []string       // Hosts that are sent Header, Cookies and basic auth - e.g. "example.com", or "example.com:8080" for one port (if empty, they aren't sent)
+	Jar             http.CookieJar // Stores cookies set by the server and sends them with later requests (optional)
+	Proxy           *url.URL       // Proxy to use (if nil, the HTTP_PROXY / HTTPS_PROXY environment variables are used)
+	Insecure        bool           // Skip TLS certificate verification - e.g. for staging environments with self-signed certificates
+	MaxConnsPerHost int            // Max number of connections to each host (zero for no limit)
+	Username        string         // Username for basic auth, sent to CredentialHosts (basic auth is only used if this is set)
+	Password        string         // Password for basic auth
+	MaxBodyBytes    int64          // Max number of bytes to read from each body (zero for no limit). Longer bodies are truncated.
+	HeadFirst       bool           // Send a HEAD request first, and only download the body if it will be used (see MediaTypes and MaxLength)
+	MediaTypes      []string       // With HeadFirst, the media types of the bodies to download (if nil, all are downloaded)
+	MaxLength       int64          // With HeadFirst, don't download bodies with a Content-Length longer than this (zero for no limit)
+	client          http.Client    // the http client to use
+	anonymous       http.Client    // the http client to use for anonymous requests, without the cookie jar
+	hosts           sync.Map       // robots.txt state for each host: scheme://host -> *host
+	once            sync.Once      // For initialisation
 }
 
 // Get returns a channel. Later it sends the response, and closes the channel.
-func (h *Getter) Get(ctx context.Context, url string) chan getter.Result {
+func (h *Getter) Get(ctx context.Context, url string, options getter.Options) chan getter.Result {
+	h.ensureInitialised()
+
 	out := make(chan getter.Result)
 	go func() {
 		// Make sure we close the channel
 		defer close(out)
 
-		// Create a standard GET request
-		req, err := http.NewRequest("GET", url, nil)
+		// Create a standard GET request, or a HEAD request if the options ask for one or the headers are checked first
+		method := "GET"
+		if options.Head || h.HeadFirst {
+			method = "HEAD"
+		}
+		req, err := http.NewRequest(method, url, nil)
 		if err != nil {
 			out <- getter.Result{Err: err}
 			return
 		}
 
-		// Add the context to the request to ensure we respect cancellation
-		req = req.WithContext(ctx)
+		// Check the robots.txt rules, and wait for the crawl-delay
+		if h.Robots {
+			if err := h.obey(ctx, req.URL); err != nil {
+				out <- getter.Result{Err: err}
+				return
+			}
+		}
+
+		// Add the context to the request to ensure we respect cancellation, and to collect the redirects
+		var redirects []getter.Redirect
+		req = req.WithContext(context.WithValue(ctx, redirectsKey{}, &redirects))
+		h.setHeaders(req, options.Anonymous)
+		addHeader(req, options.Header)
 
 		// Start the request processing
-		response, err := h.client.Do(req)
+		client := &h.client
+		if options.Anonymous {
+			client = &h.anonymous
+		}
+		response, err := client.Do(req)
+
+		switch {
+		case err != nil || method != "HEAD":
+			// great!
+		case response.StatusCode == http.StatusMethodNotAllowed || response.StatusCode == http.StatusNotImplemented:
+			// Some servers reject HEAD requests, so fall back to GET
+			response.Body.Close()
+			redirects = nil
+			req.Method = "GET"
+			response, err = client.Do(req)
+		case !options.Head && h.download(response):
+			// Get the body from the final url, so the redirects aren't followed again
+			response.Body.Close()
+			get, _ := http.NewRequest("GET", response.Request.URL.String(), nil) // can't fail, the url was already requested
+			get = get.WithContext(req.Context())
+			h.setHeaders(get, options.Anonymous)
+			addHeader(get, options.Header)
+			response, err = client.Do(get)
+		}
 
 		select {
 		case <-ctx.Done():
@@ -42,13 +106,136 @@ func (h *Getter) Get(ctx context.Context, url string) chan getter.Result {
 			return
 		default:
 			if err != nil {
-				out <- getter.Result{Err: err}
+				out <- getter.Result{Err: redirectError(err), Redirects: redirects}
 				return
 			}
+			// Limit the size of the body, reading it so the result says if it was truncated
+			body := response.Body
+			var truncated bool
+			if h.MaxBodyBytes > 0 {
+				body, truncated, err = getter.Limit(body, h.MaxBodyBytes)
+				if err != nil {
+					out <- getter.Result{Err: err, Redirects: redirects}
+					return
+				}
+			}
+			mediaType, charset := getter.ContentType(response.Header.Get("Content-Type"))
 			// Send the result on the channel - remember the caller of Get is responsible for closing Body.
-			out <- getter.Result{Code: response.StatusCode, Body: response.Body, HTML: strings.Contains(response.Header.Get("content-type"), "text/html")}
+			out <- getter.Result{
+				URL:       response.Request.URL.String(),
+				Redirects: redirects,
+				Code:      response.StatusCode,
+				Header:    response.Header,
+				Proto:     response.Proto,
+				Request:   response.Request,
+				Body:      body,
+				MediaType: mediaType,
+				Charset:   charset,
+				Truncated: truncated,
+				Skipped:   response.Request.Method == "HEAD",
+			}
 			return
 		}
 	}()
 	return out
 }
+
+// download returns true if the body of the response to a HEAD request should be downloaded
+func (h *Getter) download(response *http.Response) bool {
+	if response.StatusCode != http.StatusOK {
+		return false
+	}
+	if h.MaxLength > 0 && response.ContentLength > h.MaxLength {
+		return false
+	}
+	if h.MediaTypes == nil {
+		return true
+	}
+	mediaType, _ := getter.ContentType(response.Header.Get("Content-Type"))
+	for _, t := range h.MediaTypes {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// initialises the client
+func (h *Getter) ensureInitialised() {
+	h.once.Do(func() {
+		proxy := http.ProxyFromEnvironment
+		if h.Proxy != nil {
+			proxy = http.ProxyURL(h.Proxy)
+		}
+		// The same settings as http.DefaultTransport, with the configured options
+		h.client.Transport = &http.Transport{
+			Proxy: proxy,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSClientConfig:       &tls.Config{InsecureSkipVerify: h.Insecure},
+			MaxIdleConns:          100,
+			MaxConnsPerHost:       h.MaxConnsPerHost,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		}
+		h.client.Jar = h.Jar
+		h.client.CheckRedirect = h.checkRedirect
+		h.anonymous.Transport = h.client.Transport
+		h.anonymous.CheckRedirect = h.checkAnonymousRedirect
+	})
+}
+
+// setHeaders adds the User-Agent to a request, and the configured headers, cookies and credentials if the request is to
+// one of the CredentialHosts and isn't anonymous
+func (h *Getter) setHeaders(req *http.Request, anonymous bool) {
+	if h.UserAgent != "" {
+		req.Header.Set("User-Agent", h.UserAgent)
+	}
+	if anonymous || !h.credentialHost(req.URL) {
+		return
+	}
+	addHeader(req, h.Header)
+	for _, c := range h.Cookies {
+		req.AddCookie(c)
+	}
+	if h.Username != "" {
+		req.SetBasicAuth(h.Username, h.Password)
+	}
+}
+
+// removeCredentials removes the configured headers, cookies and credentials from a request. The http client copies
+// them from the first request when it follows a redirect.
+func (h *Getter) removeCredentials(req *http.Request) {
+	for key := range h.Header {
+		req.Header.Del(key)
+	}
+	if len(h.Cookies) > 0 {
+		// Cookies from the jar are added after the redirect is checked
+		req.Header.Del("Cookie")
+	}
+	if h.Username != "" {
+		req.Header.Del("Authorization")
+	}
+}
+
+// credentialHost returns true if the url is on one of the CredentialHosts. Hosts without a port match any port.
+func (h *Getter) credentialHost(u *url.URL) bool {
+	for _, host := range h.CredentialHosts {
+		if strings.EqualFold(host, u.Host) || !strings.Contains(host, ":") && strings.EqualFold(host, u.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
+
+// addHeader adds the headers to a request
+func addHeader(req *http.Request, header http.Header) {
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+}
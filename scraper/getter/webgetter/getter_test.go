@@ -6,7 +6,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -64,7 +67,7 @@ func TestGetter(t *testing.T) {
 				url = test.overrideURL
 			}
 
-			c := g.Get(ctx, url)
+			c := g.Get(ctx, url, getter.Options{})
 
 			var r getter.Result
 			select {
@@ -119,3 +122,512 @@ func server(returnAfter, code int, body string) *httptest.Server {
 		fmt.Fprint(w, body)
 	}))
 }
+
+func TestGetter_robots(t *testing.T) {
+	tests := []struct {
+		name   string
+		robots string
+		code   int
+		path   string
+		err    error
+		delay  time.Duration
+	}{
+		{
+			name:   "allowed",
+			robots: "User-agent: *\nDisallow: /private\n",
+			path:   "/public",
+		},
+		{
+			name:   "disallowed",
+			robots: "User-agent: *\nDisallow: /private\n",
+			path:   "/private",
+			err:    getter.ErrDisallowed,
+		},
+		{
+			name:   "user agent group",
+			robots: "User-agent: test\nDisallow: /\n\nUser-agent: *\nDisallow:\n",
+			path:   "/a",
+			err:    getter.ErrDisallowed,
+		},
+		{
+			name: "robots not found",
+			code: 404,
+			path: "/a",
+		},
+		{
+			name: "robots server error",
+			code: 500,
+			path: "/a",
+			err:  getter.ErrDisallowed,
+		},
+		{
+			name:   "crawl delay",
+			robots: "User-agent: *\nCrawl-delay: 0.05\n",
+			path:   "/a",
+			delay:  50 * time.Millisecond,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var robotsRequests int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/robots.txt" {
+					fmt.Fprint(w, "a")
+					return
+				}
+				robotsRequests++
+				if test.code != 0 {
+					w.WriteHeader(test.code)
+				}
+				fmt.Fprint(w, test.robots)
+			}))
+			defer ts.Close()
+
+			g := &Getter{Robots: true, UserAgent: "test/1.0"}
+
+			// Get the page twice to check robots.txt is cached and the crawl delay is respected
+			start := time.Now()
+			for i := 0; i < 2; i++ {
+				r := <-g.Get(context.Background(), ts.URL+test.path, getter.Options{})
+				if r.Body != nil {
+					r.Body.Close()
+				}
+				if r.Err != test.err {
+					t.Errorf("expected error %v, got %v", test.err, r.Err)
+				}
+			}
+			if elapsed := time.Since(start); elapsed < test.delay {
+				t.Errorf("expected a delay of at least %v, took %v", test.delay, elapsed)
+			}
+			if robotsRequests != 1 {
+				t.Errorf("expected robots.txt to be requested once, got %d", robotsRequests)
+			}
+		})
+	}
+}
+
+func TestGetter_robotsServerError(t *testing.T) {
+	var robotsRequests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			fmt.Fprint(w, "a")
+			return
+		}
+		robotsRequests++
+		if robotsRequests == 1 {
+			w.WriteHeader(503)
+		}
+	}))
+	defer ts.Close()
+
+	g := &Getter{Robots: true, RobotsRetry: 50 * time.Millisecond}
+
+	// The server error disallows the host until it expires, then robots.txt is fetched again
+	for _, expected := range []error{getter.ErrDisallowed, getter.ErrDisallowed, nil} {
+		if expected == nil {
+			time.Sleep(60 * time.Millisecond)
+		}
+		r := <-g.Get(context.Background(), ts.URL+"/a", getter.Options{})
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		if r.Err != expected {
+			t.Errorf("expected error %v, got %v", expected, r.Err)
+		}
+	}
+	if robotsRequests != 2 {
+		t.Errorf("expected robots.txt to be requested twice, got %d", robotsRequests)
+	}
+}
+
+func TestGetter_robotsShared(t *testing.T) {
+	release := make(chan struct{})
+	var robotsRequests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			fmt.Fprint(w, "a")
+			return
+		}
+		if atomic.AddInt32(&robotsRequests, 1) == 1 {
+			// The first request is held until its context is cancelled
+			<-release
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	g := &Getter{Robots: true}
+
+	// The first request starts the robots.txt fetch, and the second waits for it
+	ctx, cancel := context.WithCancel(context.Background())
+	first := g.Get(ctx, ts.URL+"/a", getter.Options{})
+	time.Sleep(20 * time.Millisecond)
+	second := g.Get(context.Background(), ts.URL+"/b", getter.Options{})
+	time.Sleep(20 * time.Millisecond)
+
+	// Cancelling the first request doesn't fail the second, which fetches robots.txt again
+	cancel()
+	if r := <-first; r.Err == nil {
+		t.Error("expected the first request to be cancelled")
+	}
+	select {
+	case r := <-second:
+		if r.Err != nil {
+			t.Errorf("expected the second request to succeed, got %v", r.Err)
+		} else {
+			r.Body.Close()
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the second request was held up by the first")
+	}
+	if n := atomic.LoadInt32(&robotsRequests); n != 2 {
+		t.Errorf("expected robots.txt to be requested twice, got %d", n)
+	}
+}
+
+func TestGetter_redirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		case "/c":
+			fmt.Fprint(w, "c")
+		case "/loop1":
+			http.Redirect(w, r, "/loop2", http.StatusFound)
+		case "/loop2":
+			http.Redirect(w, r, "/loop1", http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		final     string
+		redirects []getter.Redirect
+		err       error
+	}{
+		{
+			name:  "no redirect",
+			path:  "/c",
+			final: ts.URL + "/c",
+		},
+		{
+			name:      "chain",
+			path:      "/a",
+			final:     ts.URL + "/c",
+			redirects: []getter.Redirect{{URL: ts.URL + "/a", Code: 301}, {URL: ts.URL + "/b", Code: 302}},
+		},
+		{
+			name:      "loop",
+			path:      "/loop1",
+			redirects: []getter.Redirect{{URL: ts.URL + "/loop1", Code: 302}, {URL: ts.URL + "/loop2", Code: 302}},
+			err:       getter.ErrRedirectLoop,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := &Getter{}
+			r := <-g.Get(context.Background(), ts.URL+test.path, getter.Options{})
+			if r.Body != nil {
+				r.Body.Close()
+			}
+			if r.Err != test.err {
+				t.Errorf("expected error %v, got %v", test.err, r.Err)
+			}
+			if r.URL != test.final {
+				t.Errorf("expected final url %q, got %q", test.final, r.URL)
+			}
+			if !reflect.DeepEqual(r.Redirects, test.redirects) {
+				t.Errorf("unexpected redirects %#v", r.Redirects)
+			}
+		})
+	}
+}
+
+func TestGetter_options(t *testing.T) {
+	// The server echoes the request details that the options affect
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		c, _ := r.Cookie("c")
+		var cookie string
+		if c != nil {
+			cookie = c.Value
+		}
+		fmt.Fprintf(w, "%s|%s|%s|%s:%s", r.UserAgent(), r.Header.Get("X-A"), cookie, user, pass)
+	})
+
+	tests := []struct {
+		name     string
+		getter   *Getter
+		options  getter.Options
+		tls      bool
+		expected string
+		err      string
+	}{
+		{
+			name:     "none",
+			getter:   &Getter{},
+			expected: "Go-http-client/1.1|||:",
+		},
+		{
+			name: "all",
+			getter: &Getter{
+				UserAgent:       "a",
+				Header:          http.Header{"X-A": {"b"}},
+				Cookies:         []*http.Cookie{{Name: "c", Value: "d"}},
+				CredentialHosts: []string{"127.0.0.1"},
+				Username:        "e",
+				Password:        "f",
+			},
+			expected: "a|b|d|e:f",
+		},
+		{
+			name: "other host",
+			getter: &Getter{
+				UserAgent:       "a",
+				Header:          http.Header{"X-A": {"b"}},
+				Cookies:         []*http.Cookie{{Name: "c", Value: "d"}},
+				CredentialHosts: []string{"example.com"},
+				Username:        "e",
+				Password:        "f",
+			},
+			expected: "a|||:",
+		},
+		{
+			name: "anonymous",
+			getter: &Getter{
+				UserAgent:       "a",
+				Header:          http.Header{"X-A": {"b"}},
+				Cookies:         []*http.Cookie{{Name: "c", Value: "d"}},
+				CredentialHosts: []string{"127.0.0.1"},
+				Username:        "e",
+				Password:        "f",
+			},
+			options:  getter.Options{Anonymous: true},
+			expected: "a|||:",
+		},
+		{
+			name:   "tls verification",
+			getter: &Getter{},
+			tls:    true,
+			err:    "certificate",
+		},
+		{
+			name:     "tls insecure",
+			getter:   &Getter{Insecure: true},
+			tls:      true,
+			expected: "Go-http-client/1.1|||:",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var ts *httptest.Server
+			if test.tls {
+				ts = httptest.NewTLSServer(handler)
+			} else {
+				ts = httptest.NewServer(handler)
+			}
+			defer ts.Close()
+
+			r := <-test.getter.Get(context.Background(), ts.URL, test.options)
+			if test.err != "" {
+				if r.Err == nil || !strings.Contains(r.Err.Error(), test.err) {
+					t.Errorf("expected error to contain %s, got %v", test.err, r.Err)
+				}
+				return
+			}
+			if r.Err != nil {
+				t.Fatal(r.Err)
+			}
+			b, err := ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, string(b))
+			}
+		})
+	}
+}
+
+func TestGetter_credentialHosts(t *testing.T) {
+	// The other host records the credentials sent with each request
+	var m sync.Mutex
+	sent := map[string]string{}
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		defer m.Unlock()
+		sent[r.URL.Path] = r.Header.Get("X-A") + r.Header.Get("Cookie") + r.Header.Get("Authorization")
+	}))
+	defer other.Close()
+
+	// The credential host redirects to the other host, which is requested by name so it is a different host
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			return
+		}
+		http.Redirect(w, r, otherURL+"/redirected", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	g := &Getter{
+		Robots:          true,
+		Header:          http.Header{"X-A": {"b"}},
+		Cookies:         []*http.Cookie{{Name: "c", Value: "d"}},
+		CredentialHosts: []string{"127.0.0.1"},
+		Username:        "e",
+		Password:        "f",
+	}
+	for _, u := range []string{ts.URL + "/a", otherURL + "/b"} {
+		r := <-g.Get(context.Background(), u, getter.Options{})
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		r.Body.Close()
+	}
+
+	for _, path := range []string{"/robots.txt", "/redirected", "/b"} {
+		if credentials, ok := sent[path]; !ok {
+			t.Errorf("expected %s to be requested", path)
+		} else if credentials != "" {
+			t.Errorf("expected no credentials to be sent with %s, got %q", path, credentials)
+		}
+	}
+}
+
+func TestGetter_maxBodyBytes(t *testing.T) {
+	ts := server(0, 200, "0123456789")
+	defer ts.Close()
+
+	g := &Getter{MaxBodyBytes: 4}
+	r := <-g.Get(context.Background(), ts.URL, getter.Options{})
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "0123" {
+		t.Errorf("expected %q, got %q", "0123", string(b))
+	}
+	if !r.Truncated {
+		t.Error("expected body to be truncated")
+	}
+}
+
+func TestGetter_head(t *testing.T) {
+	tests := []struct {
+		name    string
+		reject  bool
+		methods []string
+	}{
+		{name: "head", methods: []string{"HEAD"}},
+		{name: "head rejected", reject: true, methods: []string{"HEAD", "GET"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var methods []string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				methods = append(methods, req.Method)
+				if test.reject && req.Method == "HEAD" {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+				w.Write([]byte("body"))
+			}))
+			defer ts.Close()
+
+			g := &Getter{}
+			r := <-g.Get(context.Background(), ts.URL, getter.Options{Head: true})
+			if r.Err != nil {
+				t.Fatal(r.Err)
+			}
+			r.Body.Close()
+			if r.Code != 200 {
+				t.Errorf("unexpected code %d", r.Code)
+			}
+			if !reflect.DeepEqual(methods, test.methods) {
+				t.Errorf("unexpected methods %#v", methods)
+			}
+		})
+	}
+}
+
+func TestGetter_headFirst(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		requests []string
+		code     int
+		body     string
+		skipped  bool
+	}{
+		{name: "html", path: "/page", requests: []string{"HEAD /page", "GET /page"}, code: 200, body: "<html>"},
+		{name: "binary", path: "/file.zip", requests: []string{"HEAD /file.zip"}, code: 200, skipped: true},
+		{name: "too long", path: "/long", requests: []string{"HEAD /long"}, code: 200, skipped: true},
+		{name: "not found", path: "/missing", requests: []string{"HEAD /missing"}, code: 404, skipped: true},
+		{name: "head rejected", path: "/rejected", requests: []string{"HEAD /rejected", "GET /rejected"}, code: 200, body: "<html>"},
+		{name: "redirect", path: "/redirect", requests: []string{"HEAD /redirect", "HEAD /page", "GET /page"}, code: 200, body: "<html>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests []string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests = append(requests, r.Method+" "+r.URL.Path)
+				switch r.URL.Path {
+				case "/page":
+					w.Header().Set("Content-Type", "text/html")
+					w.Write([]byte("<html>"))
+				case "/file.zip":
+					w.Header().Set("Content-Type", "application/zip")
+					w.Write([]byte("zip"))
+				case "/long":
+					w.Header().Set("Content-Type", "text/html")
+					w.Write([]byte(strings.Repeat("a", 100)))
+				case "/rejected":
+					if r.Method == "HEAD" {
+						w.WriteHeader(http.StatusMethodNotAllowed)
+						return
+					}
+					w.Header().Set("Content-Type", "text/html")
+					w.Write([]byte("<html>"))
+				case "/redirect":
+					http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer ts.Close()
+
+			g := &Getter{HeadFirst: true, MediaTypes: []string{"text/html"}, MaxLength: 50}
+			r := <-g.Get(context.Background(), ts.URL+test.path, getter.Options{})
+			if r.Err != nil {
+				t.Fatal(r.Err)
+			}
+			b, err := ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(requests, test.requests) {
+				t.Errorf("unexpected requests %#v", requests)
+			}
+			if r.Code != test.code {
+				t.Errorf("unexpected code %d", r.Code)
+			}
+			if string(b) != test.body {
+				t.Errorf("unexpected body %q", string(b))
+			}
+			if r.Skipped != test.skipped {
+				t.Errorf("expected skipped %v, got %v", test.skipped, r.Skipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package webgetter
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/dave/scrapy/scraper/getter"
+)
+
+// redirectsKey is the context key for the list of redirects followed by a request
+type redirectsKey struct{}
+
+// checkRedirect is used as the CheckRedirect function of the http client. It only sends the configured credentials to
+// CredentialHosts, and records the redirect.
+func (h *Getter) checkRedirect(req *http.Request, via []*http.Request) error {
+	h.removeCredentials(req)
+	h.setHeaders(req, false)
+	return recordRedirect(req, via)
+}
+
+// checkAnonymousRedirect is used as the CheckRedirect function of the http client for anonymous requests. It never
+// sends the configured credentials, and records the redirect.
+func (h *Getter) checkAnonymousRedirect(req *http.Request, via []*http.Request) error {
+	h.removeCredentials(req)
+	return recordRedirect(req, via)
+}
+
+// recordRedirect records each hop in the list stored in the request context, and stops redirect loops
+func recordRedirect(req *http.Request, via []*http.Request) error {
+	if redirects, ok := req.Context().Value(redirectsKey{}).(*[]getter.Redirect); ok {
+		*redirects = append(*redirects, getter.Redirect{URL: via[len(via)-1].URL.String(), Code: req.Response.StatusCode})
+	}
+	for _, r := range via {
+		if r.URL.String() == req.URL.String() {
+			return getter.ErrRedirectLoop
+		}
+	}
+	if len(via) >= getter.MaxRedirects {
+		return getter.ErrTooManyRedirects
+	}
+	return nil
+}
+
+// redirectError returns the error from checkRedirect if the http client wrapped it in a *url.Error, so it can be
+// compared with the getter errors
+func redirectError(err error) error {
+	if ue, ok := err.(*url.Error); ok && (ue.Err == getter.ErrRedirectLoop || ue.Err == getter.ErrTooManyRedirects) {
+		return ue.Err
+	}
+	return err
+}
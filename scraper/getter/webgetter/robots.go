@@ -0,0 +1,171 @@
+package webgetter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/robots"
+)
+
+// maxRobotsSize is the maximum size of robots.txt that will be parsed (RFC 9309 requires at least 500 KiB)
+const maxRobotsSize = 500 << 10
+
+// DefaultRobotsRetry is the default value of Getter.RobotsRetry
+const DefaultRobotsRetry = time.Minute
+
+// host contains the robots.txt rules and crawl-delay state for a single host
+type host struct {
+	m       sync.Mutex   // protects the fields below, but isn't held while robots.txt is fetched
+	data    *robots.Data // nil until robots.txt has been fetched successfully
+	expires time.Time    // when data should be fetched again (zero if it doesn't expire)
+	fetch   *fetch       // the robots.txt request in progress, or nil
+	next    time.Time    // earliest time the next request to this host may start
+}
+
+// fetch is a robots.txt request that is shared by all the requests to a host that need the rules
+type fetch struct {
+	done     chan struct{} // closed when the request has finished
+	data     *robots.Data  // the rules, if err is nil
+	err      error
+	canceled bool // was the context of the request that started the fetch cancelled?
+}
+
+// obey fetches and caches robots.txt for the host if needed, returns getter.ErrDisallowed if the url is disallowed,
+// and waits for the Crawl-delay if one is specified.
+func (h *Getter) obey(ctx context.Context, u *url.URL) error {
+	v, _ := h.hosts.LoadOrStore(u.Scheme+"://"+u.Host, &host{})
+	ho := v.(*host)
+
+	data, err := h.rules(ctx, ho, u)
+	if err != nil {
+		return err
+	}
+
+	group := data.Group(h.UserAgent)
+	if group != nil && !group.Allowed(u) {
+		return getter.ErrDisallowed
+	}
+
+	// Reserve the next slot for this host
+	var wait time.Duration
+	if group != nil && group.CrawlDelay > 0 {
+		ho.m.Lock()
+		now := time.Now()
+		if ho.next.After(now) {
+			wait = ho.next.Sub(now)
+		} else {
+			ho.next = now
+		}
+		ho.next = ho.next.Add(group.CrawlDelay)
+		ho.m.Unlock()
+	}
+
+	if wait == 0 {
+		return nil
+	}
+
+	// Wait for the crawl-delay but respect cancellation
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rules returns the cached robots.txt rules for the host, or fetches them. Concurrent requests to the host share a
+// single fetch, and each waits for it only until its own context is cancelled.
+func (h *Getter) rules(ctx context.Context, ho *host, u *url.URL) (*robots.Data, error) {
+	for {
+		ho.m.Lock()
+		if ho.data != nil && (ho.expires.IsZero() || time.Now().Before(ho.expires)) {
+			data := ho.data
+			ho.m.Unlock()
+			return data, nil
+		}
+		f := ho.fetch
+		if f == nil {
+			// Start a new fetch, which the other requests to the host will wait for
+			f = &fetch{done: make(chan struct{})}
+			ho.fetch = f
+			ho.m.Unlock()
+
+			data, temporary, err := h.fetchRobots(ctx, u)
+
+			ho.m.Lock()
+			if err == nil {
+				// Don't cache failures - the next request to this host will try again. Server errors are cached for a
+				// while, then robots.txt is fetched again.
+				ho.data = data
+				ho.expires = time.Time{}
+				if temporary {
+					ho.expires = time.Now().Add(h.robotsRetry())
+				}
+			}
+			ho.fetch = nil
+			ho.m.Unlock()
+
+			f.data, f.err, f.canceled = data, err, ctx.Err() != nil
+			close(f.done)
+			return data, err
+		}
+		ho.m.Unlock()
+
+		select {
+		case <-f.done:
+			// If the fetch was cancelled by the context of the request that started it, try again with this one
+			if f.canceled {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				continue
+			}
+			return f.data, f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// robotsRetry returns how long a robots.txt server error disallows the host
+func (h *Getter) robotsRetry() time.Duration {
+	if h.RobotsRetry > 0 {
+		return h.RobotsRetry
+	}
+	return DefaultRobotsRetry
+}
+
+// fetchRobots gets and parses robots.txt for the host of the url. Temporary is true if the rules are only valid until
+// the server recovers.
+func (h *Getter) fetchRobots(ctx context.Context, u *url.URL) (data *robots.Data, temporary bool, err error) {
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	req, err := http.NewRequest("GET", robotsURL.String(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req = req.WithContext(ctx)
+	h.setHeaders(req, false)
+
+	response, err := h.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		data, err := robots.Parse(io.LimitReader(response.Body, maxRobotsSize))
+		return data, false, err
+	case response.StatusCode >= 500:
+		// The server is unreachable, so we should assume complete disallow (RFC 9309 section 2.3.1.4) until it recovers
+		return robots.DisallowAll, true, nil
+	default:
+		// robots.txt is unavailable, so there are no restrictions
+		return robots.AllowAll, false, nil
+	}
+}
@@ -0,0 +1,272 @@
+// Package graph records the links between pages found during a crawl, and exports them as Graphviz DOT, GraphML or
+// CSV
+package graph
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dave/scrapy/scraper/parser"
+)
+
+// KindRedirect is the kind of edge from a url that was redirected to the url it redirected to
+const KindRedirect parser.Kind = "redirect"
+
+// Graph records the pages that were crawled and the links between them. It is safe for concurrent use.
+type Graph struct {
+	nodes map[string]int // status code of each page that was crawled
+	edges map[Edge]bool  // the unique edges
+	m     sync.Mutex     // protects the fields above
+	once  sync.Once      // for initialisation
+}
+
+// Edge is a link from one page to another
+type Edge struct {
+	From string      // The url of the page containing the link
+	To   string      // The url the link points to
+	Text string      // The anchor text
+	Kind parser.Kind // The kind of reference
+}
+
+// Node is a url in the graph
+type Node struct {
+	URL  string // The url
+	Code int    // The http status code (zero if the url wasn't crawled)
+}
+
+// initialises the maps
+func (g *Graph) ensureInitialised() {
+	g.once.Do(func() {
+		g.nodes = map[string]int{}
+		g.edges = map[Edge]bool{}
+	})
+}
+
+// AddPage records a page that was crawled
+func (g *Graph) AddPage(url string, code int) {
+	g.ensureInitialised()
+	g.m.Lock()
+	defer g.m.Unlock()
+	g.nodes[url] = code
+}
+
+// AddEdge records a link. Duplicate edges are only recorded once.
+func (g *Graph) AddEdge(e Edge) {
+	g.ensureInitialised()
+	g.m.Lock()
+	defer g.m.Unlock()
+	g.edges[e] = true
+}
+
+// Edges returns the edges sorted by from, to, kind and text
+func (g *Graph) Edges() []Edge {
+	g.ensureInitialised()
+	g.m.Lock()
+	defer g.m.Unlock()
+	edges := make([]Edge, 0, len(g.edges))
+	for e := range g.edges {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Text < b.Text
+	})
+	return edges
+}
+
+// Nodes returns the crawled pages and the urls they link to, sorted by url
+func (g *Graph) Nodes() []Node {
+	g.ensureInitialised()
+	g.m.Lock()
+	defer g.m.Unlock()
+	codes := map[string]int{}
+	for e := range g.edges {
+		codes[e.From] = 0
+		codes[e.To] = 0
+	}
+	for u, code := range g.nodes {
+		codes[u] = code
+	}
+	nodes := make([]Node, 0, len(codes))
+	for u, code := range codes {
+		nodes = append(nodes, Node{URL: u, Code: code})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].URL < nodes[j].URL })
+	return nodes
+}
+
+// Inbound returns the number of other pages that link to each url
+func (g *Graph) Inbound() map[string]int {
+	g.ensureInitialised()
+	g.m.Lock()
+	defer g.m.Unlock()
+	type pair struct{ from, to string }
+	seen := map[pair]bool{}
+	inbound := map[string]int{}
+	for e := range g.edges {
+		p := pair{e.From, e.To}
+		if e.From == e.To || seen[p] {
+			continue
+		}
+		seen[p] = true
+		inbound[e.To]++
+	}
+	return inbound
+}
+
+// Orphans returns the crawled pages that no other page links to, excluding the roots (e.g. the start url), sorted
+// by url. Pages are usually only crawled because they are linked, so orphans are found from other sources such as
+// sitemaps.
+func (g *Graph) Orphans(roots ...string) []string {
+	inbound := g.Inbound()
+	excluded := map[string]bool{}
+	for _, r := range roots {
+		excluded[r] = true
+	}
+	var orphans []string
+	for _, n := range g.Nodes() {
+		if n.Code != 0 && inbound[n.URL] == 0 && !excluded[n.URL] {
+			orphans = append(orphans, n.URL)
+		}
+	}
+	return orphans
+}
+
+// WriteDOT writes the graph in the Graphviz DOT format
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph scrapy {\n")
+	for _, n := range g.Nodes() {
+		if n.Code == 0 {
+			fmt.Fprintf(&b, "\t%s;\n", quote(n.URL))
+			continue
+		}
+		fmt.Fprintf(&b, "\t%s [code=%d];\n", quote(n.URL), n.Code)
+	}
+	for _, e := range g.Edges() {
+		fmt.Fprintf(&b, "\t%s -> %s [kind=%s", quote(e.From), quote(e.To), quote(string(e.Kind)))
+		if e.Text != "" {
+			fmt.Fprintf(&b, ", label=%s", quote(e.Text))
+		}
+		b.WriteString("];\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// quote returns a DOT quoted string
+func quote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
+
+// graphML is the root element of a GraphML document
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph in the GraphML format. Nodes have a url and a code, and edges have a kind and a text.
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "url", For: "node", Name: "url", Type: "string"},
+			{ID: "code", For: "node", Name: "code", Type: "int"},
+			{ID: "kind", For: "edge", Name: "kind", Type: "string"},
+			{ID: "text", For: "edge", Name: "text", Type: "string"},
+		},
+	}
+	doc.Graph.EdgeDefault = "directed"
+
+	// Urls aren't valid ids in all tools, so nodes are numbered
+	ids := map[string]string{}
+	for i, n := range g.Nodes() {
+		id := "n" + strconv.Itoa(i)
+		ids[n.URL] = id
+		node := graphMLNode{ID: id, Data: []graphMLData{{Key: "url", Value: n.URL}}}
+		if n.Code != 0 {
+			node.Data = append(node.Data, graphMLData{Key: "code", Value: strconv.Itoa(n.Code)})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, e := range g.Edges() {
+		edge := graphMLEdge{Source: ids[e.From], Target: ids[e.To], Data: []graphMLData{{Key: "kind", Value: string(e.Kind)}}}
+		if e.Text != "" {
+			edge.Data = append(edge.Data, graphMLData{Key: "text", Value: e.Text})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, edge)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteCSV writes the edges as CSV with a header row: from, to, text, kind
+func (g *Graph) WriteCSV(w io.Writer) error {
+	c := csv.NewWriter(w)
+	if err := c.Write([]string{"from", "to", "text", "kind"}); err != nil {
+		return err
+	}
+	for _, e := range g.Edges() {
+		if err := c.Write([]string{e.From, e.To, e.Text, string(e.Kind)}); err != nil {
+			return err
+		}
+	}
+	c.Flush()
+	return c.Error()
+}
@@ -0,0 +1,152 @@
+package graph
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dave/scrapy/scraper/parser"
+)
+
+// example returns a graph with a home page linking to two pages, one of which is redirected, and a page found from
+// the sitemap that nothing links to
+func example() *Graph {
+	g := &Graph{}
+	g.AddPage("/", 200)
+	g.AddPage("/a", 200)
+	g.AddPage("/b", 301)
+	g.AddPage("/c", 404)
+	g.AddPage("/d", 200)
+	g.AddEdge(Edge{From: "/", To: "/a", Text: `Say "hi"`, Kind: parser.KindAnchor})
+	g.AddEdge(Edge{From: "/", To: "/a", Text: `Say "hi"`, Kind: parser.KindAnchor})
+	g.AddEdge(Edge{From: "/", To: "/b", Kind: parser.KindAnchor})
+	g.AddEdge(Edge{From: "/", To: "/", Text: "Home", Kind: parser.KindAnchor})
+	g.AddEdge(Edge{From: "/a", To: "/img.png", Kind: parser.KindImage})
+	g.AddEdge(Edge{From: "/b", To: "/c", Kind: KindRedirect})
+	return g
+}
+
+func TestGraph(t *testing.T) {
+	g := example()
+
+	expectedNodes := []Node{{"/", 200}, {"/a", 200}, {"/b", 301}, {"/c", 404}, {"/d", 200}, {"/img.png", 0}}
+	if nodes := g.Nodes(); !reflect.DeepEqual(nodes, expectedNodes) {
+		t.Errorf("unexpected nodes %#v", nodes)
+	}
+
+	if edges := g.Edges(); len(edges) != 5 || edges[0].To != "/" || edges[4].Kind != KindRedirect {
+		t.Errorf("unexpected edges %#v", edges)
+	}
+
+	expectedInbound := map[string]int{"/a": 1, "/b": 1, "/c": 1, "/img.png": 1}
+	if inbound := g.Inbound(); !reflect.DeepEqual(inbound, expectedInbound) {
+		t.Errorf("unexpected inbound %#v", inbound)
+	}
+
+	if orphans := g.Orphans("/"); !reflect.DeepEqual(orphans, []string{"/d"}) {
+		t.Errorf("unexpected orphans %#v", orphans)
+	}
+}
+
+func TestGraph_write(t *testing.T) {
+	tests := []struct {
+		name     string
+		write    func(*Graph, *bytes.Buffer) error
+		expected string
+	}{
+		{
+			name:  "dot",
+			write: func(g *Graph, b *bytes.Buffer) error { return g.WriteDOT(b) },
+			expected: `digraph scrapy {
+	"/" [code=200];
+	"/a" [code=200];
+	"/b" [code=301];
+	"/c" [code=404];
+	"/d" [code=200];
+	"/img.png";
+	"/" -> "/" [kind="a", label="Home"];
+	"/" -> "/a" [kind="a", label="Say \"hi\""];
+	"/" -> "/b" [kind="a"];
+	"/a" -> "/img.png" [kind="img"];
+	"/b" -> "/c" [kind="redirect"];
+}
+`,
+		},
+		{
+			name:  "csv",
+			write: func(g *Graph, b *bytes.Buffer) error { return g.WriteCSV(b) },
+			expected: `from,to,text,kind
+/,/,Home,a
+/,/a,"Say ""hi""",a
+/,/b,,a
+/a,/img.png,,img
+/b,/c,,redirect
+`,
+		},
+		{
+			name:  "graphml",
+			write: func(g *Graph, b *bytes.Buffer) error { return g.WriteGraphML(b) },
+			expected: `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="url" for="node" attr.name="url" attr.type="string"></key>
+  <key id="code" for="node" attr.name="code" attr.type="int"></key>
+  <key id="kind" for="edge" attr.name="kind" attr.type="string"></key>
+  <key id="text" for="edge" attr.name="text" attr.type="string"></key>
+  <graph edgedefault="directed">
+    <node id="n0">
+      <data key="url">/</data>
+      <data key="code">200</data>
+    </node>
+    <node id="n1">
+      <data key="url">/a</data>
+      <data key="code">200</data>
+    </node>
+    <node id="n2">
+      <data key="url">/b</data>
+      <data key="code">301</data>
+    </node>
+    <node id="n3">
+      <data key="url">/c</data>
+      <data key="code">404</data>
+    </node>
+    <node id="n4">
+      <data key="url">/d</data>
+      <data key="code">200</data>
+    </node>
+    <node id="n5">
+      <data key="url">/img.png</data>
+    </node>
+    <edge source="n0" target="n0">
+      <data key="kind">a</data>
+      <data key="text">Home</data>
+    </edge>
+    <edge source="n0" target="n1">
+      <data key="kind">a</data>
+      <data key="text">Say &#34;hi&#34;</data>
+    </edge>
+    <edge source="n0" target="n2">
+      <data key="kind">a</data>
+    </edge>
+    <edge source="n1" target="n5">
+      <data key="kind">img</data>
+    </edge>
+    <edge source="n2" target="n3">
+      <data key="kind">redirect</data>
+    </edge>
+  </graph>
+</graphml>
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := test.write(example(), &b); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != test.expected {
+				t.Errorf("unexpected output:\n%s", b.String())
+			}
+		})
+	}
+}
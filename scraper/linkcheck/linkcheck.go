@@ -0,0 +1,146 @@
+// Package linkcheck records the pages that link to each url and the status of each url, and reports broken links
+package linkcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/parser"
+)
+
+// Checker records the pages that link to each url and the status of each url. A nil *Checker records nothing. It is
+// safe for concurrent use.
+type Checker struct {
+	referrers map[string][]Referrer        // the pages that link to each url
+	seen      map[string]map[Referrer]bool // used to record each referrer once
+	results   map[string]Broken            // the status of each url (Referrers isn't set)
+	m         sync.Mutex                   // protects the maps above
+	once      sync.Once                    // for initialisation
+}
+
+// Referrer is a link to a url
+type Referrer struct {
+	URL  string      // The url of the page containing the link
+	Text string      // The anchor text
+	Kind parser.Kind // The kind of reference
+}
+
+// Broken is a url that returned an error status code, or couldn't be got
+type Broken struct {
+	URL       string     // The url
+	Code      int        // The http status code (zero if there was an error)
+	Err       error      // The error
+	Referrers []Referrer // The links to the url, sorted by url
+}
+
+// Reason returns a description of why the url is broken - e.g. "response code 404"
+func (b Broken) Reason() string {
+	if b.Err != nil {
+		return b.Err.Error()
+	}
+	return fmt.Sprintf("response code %d", b.Code)
+}
+
+// initialises the maps
+func (c *Checker) ensureInitialised() {
+	c.once.Do(func() {
+		c.referrers = map[string][]Referrer{}
+		c.seen = map[string]map[Referrer]bool{}
+		c.results = map[string]Broken{}
+	})
+}
+
+// Link records a link from a page
+func (c *Checker) Link(from string, link parser.Link) {
+	if c == nil {
+		return
+	}
+	c.ensureInitialised()
+	c.m.Lock()
+	defer c.m.Unlock()
+	r := Referrer{URL: from, Text: link.Text, Kind: link.Kind}
+	if c.seen[link.URL] == nil {
+		c.seen[link.URL] = map[Referrer]bool{}
+	}
+	if c.seen[link.URL][r] {
+		return
+	}
+	c.seen[link.URL][r] = true
+	c.referrers[link.URL] = append(c.referrers[link.URL], r)
+}
+
+// Result records the status code of a url, or the error if it couldn't be got
+func (c *Checker) Result(url string, code int, err error) {
+	if c == nil {
+		return
+	}
+	c.ensureInitialised()
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.results[url] = Broken{URL: url, Code: code, Err: err}
+}
+
+// Broken returns the urls that returned a status code of 400 or more, or couldn't be got, with the links to them,
+// sorted by url. Urls that were disallowed by robots.txt or interrupted by cancellation aren't broken.
+func (c *Checker) Broken() []Broken {
+	if c == nil {
+		return nil
+	}
+	c.ensureInitialised()
+	c.m.Lock()
+	defer c.m.Unlock()
+	var broken []Broken
+	for url, b := range c.results {
+		switch {
+		case b.Err == getter.ErrDisallowed, b.Err == context.Canceled:
+			continue
+		case b.Err == nil && b.Code < 400:
+			continue
+		}
+		b.Referrers = append([]Referrer(nil), c.referrers[url]...)
+		sort.Slice(b.Referrers, func(i, j int) bool {
+			x, y := b.Referrers[i], b.Referrers[j]
+			if x.URL != y.URL {
+				return x.URL < y.URL
+			}
+			if x.Kind != y.Kind {
+				return x.Kind < y.Kind
+			}
+			return x.Text < y.Text
+		})
+		broken = append(broken, b)
+	}
+	sort.Slice(broken, func(i, j int) bool { return broken[i].URL < broken[j].URL })
+	return broken
+}
+
+// WriteReport writes the broken links, each followed by the pages that link to it and the anchor text (or the kind of
+// reference for other kinds of link)
+func (c *Checker) WriteReport(w io.Writer) error {
+	broken := c.Broken()
+	var b strings.Builder
+	fmt.Fprintf(&b, "Broken links: %d\n", len(broken))
+	for _, br := range broken {
+		fmt.Fprintf(&b, "\n%s: %s\n", br.URL, br.Reason())
+		if len(br.Referrers) == 0 {
+			b.WriteString("  (not linked)\n")
+		}
+		for _, r := range br.Referrers {
+			switch {
+			case r.Kind != parser.KindAnchor:
+				fmt.Fprintf(&b, "  %s (%s)\n", r.URL, r.Kind)
+			case r.Text != "":
+				fmt.Fprintf(&b, "  %s %q\n", r.URL, r.Text)
+			default:
+				fmt.Fprintf(&b, "  %s\n", r.URL)
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
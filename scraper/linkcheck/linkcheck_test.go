@@ -0,0 +1,62 @@
+package linkcheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/parser"
+)
+
+func TestChecker(t *testing.T) {
+	c := &Checker{}
+	c.Link("/", parser.Link{URL: "/a", Text: "A", Kind: parser.KindAnchor})
+	c.Link("/", parser.Link{URL: "/b", Text: "Missing", Kind: parser.KindAnchor})
+	c.Link("/", parser.Link{URL: "/b", Text: "Missing", Kind: parser.KindAnchor})
+	c.Link("/a", parser.Link{URL: "/b", Kind: parser.KindAnchor})
+	c.Link("/a", parser.Link{URL: "/c.png", Kind: parser.KindImage})
+	c.Link("/a", parser.Link{URL: "http://x/", Text: "X", Kind: parser.KindAnchor})
+	c.Link("/a", parser.Link{URL: "/private", Kind: parser.KindAnchor})
+	c.Result("/", 200, nil)
+	c.Result("/a", 200, nil)
+	c.Result("/b", 404, nil)
+	c.Result("/c.png", 500, nil)
+	c.Result("http://x/", 0, errors.New("no such host"))
+	c.Result("/private", 0, getter.ErrDisallowed)
+	c.Result("/slow", 0, context.Canceled)
+	c.Result("/gone", 410, nil)
+
+	var b bytes.Buffer
+	if err := c.WriteReport(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := `Broken links: 4
+
+/b: response code 404
+  / "Missing"
+  /a
+
+/c.png: response code 500
+  /a (img)
+
+/gone: response code 410
+  (not linked)
+
+http://x/: no such host
+  /a "X"
+`
+	if b.String() != expected {
+		t.Errorf("unexpected report:\n%s", b.String())
+	}
+}
+
+func TestChecker_nil(t *testing.T) {
+	var c *Checker
+	c.Link("/", parser.Link{URL: "/a"})
+	c.Result("/a", 404, nil)
+	if broken := c.Broken(); broken != nil {
+		t.Errorf("unexpected broken links %#v", broken)
+	}
+}
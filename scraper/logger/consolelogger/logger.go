@@ -13,20 +13,33 @@ import (
 	"time"
 
 	"github.com/dave/ghistogram"
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/logger"
 	"github.com/dave/scrapy/scraper/queuer"
 )
 
 // Logger is a logger.Interface that emits logs to a writer (usually the console)
 type Logger struct {
-	Writer                               io.Writer             // where to print the logs
-	successfulUrls                       []string              // all successful urls (will be sorted and listed at exit)
-	lastURLStarted                       string                // last url that started processing
-	lastErr                              error                 // last error received
-	queued, started, errs, success, full uint64                // counters for various stats
-	ticker                               *time.Ticker          // ticker ticks every 200ms to display stats
-	exiting                              bool                  // used to ensure stats don't display after ticker is stopped
-	hist                                 *ghistogram.Histogram // displays a histogram of latencies
-	m                                    sync.Mutex            // If ultimate performance was a concern, we could have a mutex per variable but this will simplify
+	Writer                                        io.Writer             // where to print the logs
+	successfulUrls                                []string              // all successful urls (will be sorted and listed at exit)
+	lastURLStarted                                string                // last url that started processing
+	lastErr                                       error                 // last error received
+	queued, started, errs, success, full, blocked uint64                // counters for various stats
+	noindex                                       uint64                // counts pages that asked not to be indexed
+	suppressed                                    map[string]uint64     // counts links suppressed by nofollow rules, by reason
+	sitemap                                       bool                  // has the sitemap report been received?
+	orphans, missing                              []string              // sitemap urls that were never linked, and crawled pages missing from the sitemap
+	redirected, loops, upgrades                   uint64                // counts redirected pages, redirect loops and http to https hops
+	retries                                       uint64                // counts requests that were retried
+	truncated                                     uint64                // counts pages that were truncated by the body size limit
+	skipped                                       uint64                // counts pages whose body was skipped, so they weren't parsed
+	hits, revalidated, misses                     uint64                // counts pages by how they were found in the cache
+	encodings                                     map[string]uint64     // counts pages by character encoding
+	redirects                                     []string              // redirect chains and loops (will be sorted and listed at exit)
+	ticker                                        *time.Ticker          // ticker ticks every 200ms to display stats
+	exiting                                       bool                  // used to ensure stats don't display after ticker is stopped
+	hist                                          *ghistogram.Histogram // displays a histogram of latencies
+	m                                             sync.Mutex            // If ultimate performance was a concern, we could have a mutex per variable but this will simplify
 }
 
 // printSummary prints a summary of the logs to the writer
@@ -43,6 +56,15 @@ func (l *Logger) printSummary() {
 	fmt.Fprintf(w, "In progress\t%d\t%s\n", stats.inProgress, l.getLastURLStarted())
 	fmt.Fprintf(w, "Success\t%d\n", stats.success)
 	fmt.Fprintf(w, "Errors\t%d\t%s\n", stats.allErrors, l.getLastErr())
+	fmt.Fprintf(w, "Blocked\t%d\n", stats.blocked)
+	fmt.Fprintf(w, "Retries\t%d\n", atomic.LoadUint64(&l.retries))
+	fmt.Fprintf(w, "No index\t%d\n", atomic.LoadUint64(&l.noindex))
+	fmt.Fprintf(w, "Truncated\t%d\n", atomic.LoadUint64(&l.truncated))
+	fmt.Fprintf(w, "Skipped\t%d\n", atomic.LoadUint64(&l.skipped))
+	fmt.Fprintf(w, "Cache\t%d hits, %d revalidated, %d misses\n", atomic.LoadUint64(&l.hits), atomic.LoadUint64(&l.revalidated), atomic.LoadUint64(&l.misses))
+	fmt.Fprintf(w, "Encodings\t%s\n", l.getEncodings())
+	fmt.Fprintf(w, "Suppressed\t%s\n", l.getSuppressed())
+	fmt.Fprintf(w, "Redirects\t%d\t%d loops, %d http to https\n", atomic.LoadUint64(&l.redirected), atomic.LoadUint64(&l.loops), atomic.LoadUint64(&l.upgrades))
 	w.Flush()
 
 	// l.printMemStats()
@@ -85,25 +107,61 @@ func (l *Logger) Queued(url string) {
 }
 
 // Starting is called each time a url starts processing
-func (l *Logger) Starting(url string) {
+func (l *Logger) Starting(url string, depth int) {
 	atomic.AddUint64(&l.started, 1)
 	l.setLastURLStarted(url)
 }
 
 // Finished is called each time a URL successfully finishes processing (even for non-200 results)
-func (l *Logger) Finished(url string, code int, latency time.Duration, urls, errors int) {
+func (l *Logger) Finished(url string, stats logger.Stats) {
 
 	// Log the latency for all finished requests for the histogram
-	l.hist.Add(uint64(latency/time.Millisecond), 1)
+	l.hist.Add(uint64(stats.Latency/time.Millisecond), 1)
+
+	if len(stats.Redirects) > 0 {
+		l.addRedirect(stats)
+	}
+
+	switch stats.Cache {
+	case getter.CacheHit:
+		atomic.AddUint64(&l.hits, 1)
+	case getter.CacheRevalidated:
+		atomic.AddUint64(&l.revalidated, 1)
+	case getter.CacheMiss:
+		atomic.AddUint64(&l.misses, 1)
+	}
 
 	// If the code isn't 200, log as an error
-	if code != 200 {
+	if stats.Code != 200 {
 		atomic.AddUint64(&l.errs, 1)
-		l.setLastErr(fmt.Errorf("response code %d: %s", code, url))
+		l.setLastErr(fmt.Errorf("response code %d: %s", stats.Code, url))
+		return
+	}
+
+	// Pages whose body was skipped weren't parsed, so they aren't counted as successes
+	if stats.Skipped {
+		atomic.AddUint64(&l.skipped, 1)
 		return
 	}
 
+	l.addSuppressed(stats.Suppressed)
+
+	if stats.Truncated {
+		atomic.AddUint64(&l.truncated, 1)
+	}
+
+	if stats.Encoding != "" {
+		l.addEncoding(stats.Encoding)
+	}
+
 	atomic.AddUint64(&l.success, 1)
+
+	// Pages that asked not to be indexed are not listed
+	if stats.NoIndex {
+		atomic.AddUint64(&l.noindex, 1)
+		return
+	}
+
 	l.addURLSuccess(url)
 }
 
@@ -118,12 +176,59 @@ func (l *Logger) Error(url string, err error) {
 	case queuer.ErrFull:
 		atomic.AddUint64(&l.full, 1)
 		l.setLastErr(err)
+	case getter.ErrDisallowed:
+		// urls blocked by robots.txt are not failures, so are counted separately
+		atomic.AddUint64(&l.blocked, 1)
+	case getter.ErrRedirectLoop, getter.ErrTooManyRedirects:
+		atomic.AddUint64(&l.loops, 1)
+		atomic.AddUint64(&l.errs, 1)
+		l.setLastErr(err)
+		l.m.Lock()
+		l.redirects = append(l.redirects, fmt.Sprintf("%s: %v", url, err))
+		l.m.Unlock()
 	default:
 		atomic.AddUint64(&l.errs, 1)
 		l.setLastErr(err)
 	}
 }
 
+// RedirectError is called instead of Error when a request fails after following redirects. Redirect loops are reported
+// with the chain of redirects - e.g. "http://a -301-> https://a -302-> redirect loop"
+func (l *Logger) RedirectError(url string, redirects []getter.Redirect, err error) {
+	if err != getter.ErrRedirectLoop && err != getter.ErrTooManyRedirects {
+		l.Error(url, err)
+		return
+	}
+	atomic.AddUint64(&l.loops, 1)
+	atomic.AddUint64(&l.errs, 1)
+	l.setLastErr(err)
+
+	chain := &strings.Builder{}
+	for _, r := range redirects {
+		fmt.Fprintf(chain, "%s -%d-> ", r.URL, r.Code)
+	}
+	chain.WriteString(err.Error())
+
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.redirects = append(l.redirects, chain.String())
+}
+
+// Retrying is called each time a request fails and will be retried after the delay
+func (l *Logger) Retrying(url string, attempt int, delay time.Duration, err error) {
+	atomic.AddUint64(&l.retries, 1)
+}
+
+// Sitemap is called before Exit with the sitemap urls that were never linked, and the crawled pages missing from the
+// sitemap
+func (l *Logger) Sitemap(orphans, missing []string) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.sitemap = true
+	l.orphans = orphans
+	l.missing = missing
+}
+
 // Exit stops the status ticker, and prints a sorted list of the successful urls
 func (l *Logger) Exit() {
 
@@ -137,6 +242,26 @@ func (l *Logger) Exit() {
 	for _, u := range l.successfulUrls {
 		fmt.Fprintln(l.Writer, u)
 	}
+
+	if len(l.redirects) > 0 {
+		sort.Strings(l.redirects)
+		l.printList("Redirects", l.redirects)
+	}
+
+	if l.sitemap {
+		l.printList("Orphans (in the sitemap but never linked)", l.orphans)
+		l.printList("Missing from the sitemap", l.missing)
+	}
+}
+
+// printList prints a titled list of urls
+func (l *Logger) printList(title string, urls []string) {
+	fmt.Fprintln(l.Writer, "")
+	fmt.Fprintln(l.Writer, title)
+	fmt.Fprintln(l.Writer, strings.Repeat("-", len(title)))
+	for _, u := range urls {
+		fmt.Fprintln(l.Writer, u)
+	}
 }
 
 func (l *Logger) isExiting() bool {
@@ -185,8 +310,79 @@ func (l *Logger) addURLSuccess(url string) {
 	l.successfulUrls = append(l.successfulUrls, url)
 }
 
+// addRedirect counts a redirected page and stores the redirect chain - e.g. "http://a -301-> https://a -302-> https://a/b"
+func (l *Logger) addRedirect(stats logger.Stats) {
+	atomic.AddUint64(&l.redirected, 1)
+
+	chain := &strings.Builder{}
+	for i, r := range stats.Redirects {
+		next := stats.URL
+		if i < len(stats.Redirects)-1 {
+			next = stats.Redirects[i+1].URL
+		}
+		if strings.HasPrefix(r.URL, "http:") && strings.HasPrefix(next, "https:") {
+			atomic.AddUint64(&l.upgrades, 1)
+		}
+		fmt.Fprintf(chain, "%s -%d-> ", r.URL, r.Code)
+	}
+	chain.WriteString(stats.URL)
+
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.redirects = append(l.redirects, chain.String())
+}
+
+func (l *Logger) addSuppressed(suppressed map[string]int) {
+	if len(suppressed) == 0 {
+		return
+	}
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.suppressed == nil {
+		l.suppressed = map[string]uint64{}
+	}
+	for reason, count := range suppressed {
+		l.suppressed[reason] += uint64(count)
+	}
+}
+
+func (l *Logger) addEncoding(encoding string) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.encodings == nil {
+		l.encodings = map[string]uint64{}
+	}
+	l.encodings[encoding]++
+}
+
+// getEncodings returns the number of pages with each character encoding
+func (l *Logger) getEncodings() string {
+	l.m.Lock()
+	defer l.m.Unlock()
+	var encodings []string
+	for encoding, count := range l.encodings {
+		encodings = append(encodings, fmt.Sprintf("%s: %d", encoding, count))
+	}
+	sort.Strings(encodings)
+	return strings.Join(encodings, ", ")
+}
+
+// getSuppressed returns the total number of suppressed links, followed by the count for each reason
+func (l *Logger) getSuppressed() string {
+	l.m.Lock()
+	defer l.m.Unlock()
+	var total uint64
+	var reasons []string
+	for reason, count := range l.suppressed {
+		total += count
+		reasons = append(reasons, fmt.Sprintf("%s: %d", reason, count))
+	}
+	sort.Strings(reasons)
+	return strings.TrimSpace(fmt.Sprintf("%d\t%s", total, strings.Join(reasons, ", ")))
+}
+
 type displayStats struct {
-	inQueue, inProgress, success, allErrors uint64
+	inQueue, inProgress, success, allErrors, blocked uint64
 }
 
 func (l *Logger) loadDisplayStats() displayStats {
@@ -196,12 +392,15 @@ func (l *Logger) loadDisplayStats() displayStats {
 		errs    = atomic.LoadUint64(&l.errs)
 		success = atomic.LoadUint64(&l.success)
 		full    = atomic.LoadUint64(&l.full)
+		blocked = atomic.LoadUint64(&l.blocked)
+		skipped = atomic.LoadUint64(&l.skipped)
 	)
 	return displayStats{
 		inQueue:    queued - started,
-		inProgress: started - success - errs,
+		inProgress: started - success - errs - blocked - skipped,
 		success:    success,
 		allErrors:  errs + full,
+		blocked:    blocked,
 	}
 }
 
@@ -0,0 +1,38 @@
+package consolelogger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dave/scrapy/scraper/logger"
+)
+
+func TestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := &Logger{Writer: buf}
+	l.Init()
+	for _, u := range []string{"https://a/", "https://a/b.zip", "https://a/c"} {
+		l.Queued(u)
+		l.Starting(u, 0)
+	}
+	l.Finished("https://a/", logger.Stats{URL: "https://a/", Code: 200})
+	l.Finished("https://a/b.zip", logger.Stats{URL: "https://a/b.zip", Code: 200, Skipped: true})
+
+	// Only https://a/c is still in progress: the skipped page has finished, but isn't a success
+	stats := l.loadDisplayStats()
+	if stats.inProgress != 1 {
+		t.Errorf("expected 1 in progress, found %d", stats.inProgress)
+	}
+	if stats.success != 1 {
+		t.Errorf("expected 1 success, found %d", stats.success)
+	}
+	if stats.inQueue != 0 {
+		t.Errorf("expected 0 queued, found %d", stats.inQueue)
+	}
+
+	l.Exit()
+	if out := buf.String(); !strings.Contains(out, "URLs\n----\nhttps://a/\n") || strings.Contains(out, "b.zip") {
+		t.Errorf("expected only https://a/ to be listed, found:\n%s", out)
+	}
+}
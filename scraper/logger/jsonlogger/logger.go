@@ -0,0 +1,171 @@
+// Package jsonlogger defines a logger.Interface that writes one JSON object per event to a writer (JSON Lines)
+package jsonlogger
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/logger"
+	"github.com/dave/scrapy/scraper/queuer"
+)
+
+// Logger is a logger.Interface that writes one JSON object per event to a writer (JSON Lines)
+type Logger struct {
+	Writer io.Writer     // where to write the events (default os.Stdout)
+	enc    *json.Encoder // encodes the events to the writer
+	m      sync.Mutex    // ensures events aren't interleaved
+}
+
+// header contains the fields common to all events
+type header struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	URL   string    `json:"url,omitempty"`
+}
+
+type starting struct {
+	header
+	Depth int `json:"depth"`
+}
+
+type finished struct {
+	header
+	Depth      int            `json:"depth"`
+	FinalURL   string         `json:"final_url,omitempty"`
+	Redirects  []redirect     `json:"redirects,omitempty"`
+	Code       int            `json:"code"`
+	MediaType  string         `json:"media_type,omitempty"`
+	Encoding   string         `json:"encoding,omitempty"`
+	LatencyMs  float64        `json:"latency_ms"`
+	Links      int            `json:"links"`
+	Errors     int            `json:"errors"`
+	Suppressed map[string]int `json:"suppressed,omitempty"`
+	NoIndex    bool           `json:"noindex,omitempty"`
+	Truncated  bool           `json:"truncated,omitempty"`
+	Skipped    bool           `json:"skipped,omitempty"`
+	Cache      string         `json:"cache,omitempty"`
+}
+
+type redirect struct {
+	URL  string `json:"url"`
+	Code int    `json:"code"`
+}
+
+type failed struct {
+	header
+	Redirects []redirect `json:"redirects,omitempty"`
+	Error     string     `json:"error"`
+}
+
+type retrying struct {
+	header
+	Attempt int     `json:"attempt"`
+	DelayMs float64 `json:"delay_ms"`
+	Error   string  `json:"error"`
+}
+
+type sitemap struct {
+	header
+	Orphans []string `json:"orphans"`
+	Missing []string `json:"missing"`
+}
+
+// Init initialises the logger
+func (l *Logger) Init() {
+	// Default to stdout if no Writer is specified
+	if l.Writer == nil {
+		l.Writer = os.Stdout
+	}
+	l.enc = json.NewEncoder(l.Writer)
+}
+
+// Queued is called each time a url is successfully queued
+func (l *Logger) Queued(url string) {
+	l.write(l.header("queued", url))
+}
+
+// Starting is called each time a url starts processing
+func (l *Logger) Starting(url string, depth int) {
+	l.write(starting{header: l.header("starting", url), Depth: depth})
+}
+
+// Finished is called each time a URL successfully finishes processing (even for non-200 results)
+func (l *Logger) Finished(url string, stats logger.Stats) {
+	e := finished{
+		header:     l.header("finished", url),
+		Depth:      stats.Depth,
+		Code:       stats.Code,
+		MediaType:  stats.MediaType,
+		Encoding:   stats.Encoding,
+		LatencyMs:  milliseconds(stats.Latency),
+		Links:      stats.Urls,
+		Errors:     stats.Errors,
+		Suppressed: stats.Suppressed,
+		NoIndex:    stats.NoIndex,
+		Truncated:  stats.Truncated,
+		Skipped:    stats.Skipped,
+		Cache:      stats.Cache,
+	}
+	if stats.URL != url {
+		e.FinalURL = stats.URL
+	}
+	for _, r := range stats.Redirects {
+		e.Redirects = append(e.Redirects, redirect{URL: r.URL, Code: r.Code})
+	}
+	l.write(e)
+}
+
+// Error is called on every error
+func (l *Logger) Error(url string, err error) {
+	if err == queuer.ErrDuplicate {
+		// duplicates are expected, so aren't logged
+		return
+	}
+	l.write(failed{header: l.header("error", url), Error: err.Error()})
+}
+
+// RedirectError is called instead of Error when a request fails after following redirects
+func (l *Logger) RedirectError(url string, redirects []getter.Redirect, err error) {
+	e := failed{header: l.header("error", url), Error: err.Error()}
+	for _, r := range redirects {
+		e.Redirects = append(e.Redirects, redirect{URL: r.URL, Code: r.Code})
+	}
+	l.write(e)
+}
+
+// Retrying is called each time a request fails and will be retried after the delay
+func (l *Logger) Retrying(url string, attempt int, delay time.Duration, err error) {
+	l.write(retrying{header: l.header("retrying", url), Attempt: attempt, DelayMs: milliseconds(delay), Error: err.Error()})
+}
+
+// Sitemap is called before Exit with the sitemap urls that were never linked, and the crawled pages missing from the
+// sitemap
+func (l *Logger) Sitemap(orphans, missing []string) {
+	l.write(sitemap{header: l.header("sitemap", ""), Orphans: orphans, Missing: missing})
+}
+
+// Exit is called when the queue has finished and the logger should finalise
+func (l *Logger) Exit() {
+	l.write(l.header("exit", ""))
+}
+
+// header returns the common fields for an event
+func (l *Logger) header(event, url string) header {
+	return header{Time: time.Now().UTC(), Event: event, URL: url}
+}
+
+// write writes an event as a line of JSON. Errors writing are ignored, as there's nowhere to report them.
+func (l *Logger) write(event interface{}) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.enc.Encode(event)
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,86 @@
+package jsonlogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/logger"
+	"github.com/dave/scrapy/scraper/queuer"
+)
+
+func TestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := &Logger{Writer: buf}
+	l.Init()
+	l.Queued("https://a/")
+	l.Starting("https://a/", 0)
+	l.Finished("https://a/", logger.Stats{
+		URL:       "https://b/",
+		Redirects: []getter.Redirect{{URL: "https://a/", Code: 301}},
+		Code:      200,
+		MediaType: "text/html",
+		Latency:   1500 * time.Microsecond,
+		Urls:      2,
+		NoIndex:   true,
+	})
+	l.Error("https://c/", queuer.ErrDuplicate)
+	l.Error("https://c/", errors.New("a"))
+	l.RedirectError("https://d/", []getter.Redirect{{URL: "https://d/", Code: 302}}, getter.ErrRedirectLoop)
+	l.Retrying("https://e/", 2, time.Second, errors.New("b"))
+	l.Sitemap([]string{"https://f/"}, nil)
+	l.Exit()
+
+	expected := []map[string]interface{}{
+		{"event": "queued", "url": "https://a/"},
+		{"event": "starting", "url": "https://a/", "depth": 0.0},
+		{
+			"event":      "finished",
+			"url":        "https://a/",
+			"depth":      0.0,
+			"final_url":  "https://b/",
+			"redirects":  []interface{}{map[string]interface{}{"url": "https://a/", "code": 301.0}},
+			"code":       200.0,
+			"media_type": "text/html",
+			"latency_ms": 1.5,
+			"links":      2.0,
+			"errors":     0.0,
+			"noindex":    true,
+		},
+		{"event": "error", "url": "https://c/", "error": "a"},
+		{
+			"event":     "error",
+			"url":       "https://d/",
+			"redirects": []interface{}{map[string]interface{}{"url": "https://d/", "code": 302.0}},
+			"error":     getter.ErrRedirectLoop.Error(),
+		},
+		{"event": "retrying", "url": "https://e/", "attempt": 2.0, "delay_ms": 1000.0, "error": "b"},
+		{"event": "sitemap", "orphans": []interface{}{"https://f/"}, "missing": nil},
+		{"event": "exit"},
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, found %d:\n%s", len(expected), len(lines), buf.String())
+	}
+	for i, line := range lines {
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Errorf("line %d: %v", i, err)
+			continue
+		}
+		// Every event has a time, which is removed before comparing
+		if _, err := time.Parse(time.RFC3339Nano, event["time"].(string)); err != nil {
+			t.Errorf("line %d: %v", i, err)
+		}
+		delete(event, "time")
+		if !reflect.DeepEqual(event, expected[i]) {
+			t.Errorf("line %d: expected %#v, found %#v", i, expected[i], event)
+		}
+	}
+}
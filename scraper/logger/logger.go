@@ -1,15 +1,43 @@
 // Package logger defines an interface that is used to log events and metrics during execution
 package logger
 
-import "time"
+import (
+	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+)
 
 // Interface is used to log events and metrics during execution
 type Interface interface {
-	Init()               // Initialise the logger
-	Queued(url string)   // Queued is called each time a url is successfully queued
-	Starting(url string) // Starting is called each time a url starts processing
-	Finished(url string, code int, latency time.Duration,
-		urls, errors int) // Finished is called each time a URL successfully finishes processing (even for non-200 results)
-	Error(url string, err error) // Error is called on every error
-	Exit()                       // Exit is called when the queue has finished and the logger should finalise
+	Init()                                                            // Initialise the logger
+	Queued(url string)                                                // Queued is called each time a url is successfully queued
+	Starting(url string, depth int)                                   // Starting is called each time a url starts processing
+	Finished(url string, stats Stats)                                 // Finished is called each time a URL successfully finishes processing (even for non-200 results)
+	Error(url string, err error)                                      // Error is called on every error
+	Retrying(url string, attempt int, delay time.Duration, err error) // Retrying is called each time a request fails and will be retried after the delay
+	Sitemap(orphans, missing []string)                                // Sitemap is called before Exit with the sitemap urls that were never linked, and the crawled pages missing from the sitemap
+	Exit()                                                            // Exit is called when the queue has finished and the logger should finalise
+}
+
+// Redirecter is implemented by loggers that report the redirects followed by requests that failed - e.g. redirect loops
+type Redirecter interface {
+	RedirectError(url string, redirects []getter.Redirect, err error) // RedirectError is called instead of Error when a request fails after following redirects
+}
+
+// Stats contains information about a url that finished processing
+type Stats struct {
+	URL        string            // The final url, if the page was redirected
+	Redirects  []getter.Redirect // The redirects that were followed to get to the final url
+	Depth      int               // Number of links followed from the start url
+	Code       int               // The http status code
+	MediaType  string            // The media type of the document - e.g. "text/html"
+	Encoding   string            // The character encoding detected by the parser - e.g. "windows-1252"
+	Latency    time.Duration     // Time taken to get and parse the page
+	Urls       int               // Number of urls found by the parser
+	Errors     int               // Number of parse errors
+	Suppressed map[string]int    // Number of links suppressed by nofollow rules, by reason
+	NoIndex    bool              // Did the page ask not to be indexed?
+	Truncated  bool              // Was the body truncated by a size limit?
+	Skipped    bool              // Was the body skipped, so the page wasn't parsed? (only the status and headers were requested)
+	Cache      string            // How the page was found in the cache - e.g. getter.CacheHit (empty if not cached)
 }
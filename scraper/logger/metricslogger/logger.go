@@ -0,0 +1,219 @@
+// Package metricslogger defines a logger.Interface that maintains crawl metrics and serves them over HTTP in the
+// Prometheus text format
+package metricslogger
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/logger"
+	"github.com/dave/scrapy/scraper/queuer"
+)
+
+// Logger is a logger.Interface that maintains crawl metrics, and is a http.Handler that serves them in the Prometheus
+// text format
+type Logger struct {
+	Buckets  []float64         // Upper bounds of the latency histogram buckets in seconds (default DefaultBuckets)
+	queued   uint64            // urls queued
+	started  uint64            // urls started
+	retries  uint64            // requests retried
+	full     uint64            // urls dropped because the queue was full
+	finished map[string]uint64 // urls finished by status code class - e.g. "2xx"
+	errors   map[string]uint64 // errors by type
+	cache    map[string]uint64 // urls finished by cache result - e.g. "hit"
+	counts   []uint64          // latency histogram counts for each bucket (not cumulative)
+	sum      float64           // sum of latencies in seconds
+	count    uint64            // number of latencies
+	m        sync.Mutex        // protects the fields above
+	once     sync.Once         // For initialisation
+}
+
+// DefaultBuckets are the default upper bounds of the latency histogram buckets in seconds
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// initialises the metrics
+func (l *Logger) ensureInitialised() {
+	l.once.Do(func() {
+		if l.Buckets == nil {
+			l.Buckets = DefaultBuckets
+		}
+		l.counts = make([]uint64, len(l.Buckets))
+		l.finished = map[string]uint64{}
+		l.errors = map[string]uint64{}
+		l.cache = map[string]uint64{}
+	})
+}
+
+// Init initialises the logger
+func (l *Logger) Init() {
+	l.ensureInitialised()
+}
+
+// Queued is called each time a url is successfully queued
+func (l *Logger) Queued(url string) {
+	l.ensureInitialised()
+	l.m.Lock()
+	l.queued++
+	l.m.Unlock()
+}
+
+// Starting is called each time a url starts processing
+func (l *Logger) Starting(url string, depth int) {
+	l.ensureInitialised()
+	l.m.Lock()
+	l.started++
+	l.m.Unlock()
+}
+
+// Finished is called each time a URL successfully finishes processing (even for non-200 results)
+func (l *Logger) Finished(url string, stats logger.Stats) {
+	l.ensureInitialised()
+	l.m.Lock()
+	l.finished[fmt.Sprintf("%dxx", stats.Code/100)]++
+	if stats.Cache != "" {
+		l.cache[stats.Cache]++
+	}
+	seconds := stats.Latency.Seconds()
+	for i, bound := range l.Buckets {
+		if seconds <= bound {
+			l.counts[i]++
+			break
+		}
+	}
+	l.sum += seconds
+	l.count++
+	l.m.Unlock()
+}
+
+// Error is called on every error
+func (l *Logger) Error(url string, err error) {
+	l.ensureInitialised()
+	l.m.Lock()
+	switch err {
+	case queuer.ErrDuplicate:
+		// duplicates are expected, so aren't counted
+	case queuer.ErrFull:
+		l.full++
+	default:
+		l.errors[errorType(err)]++
+	}
+	l.m.Unlock()
+}
+
+// Retrying is called each time a request fails and will be retried after the delay
+func (l *Logger) Retrying(url string, attempt int, delay time.Duration, err error) {
+	l.ensureInitialised()
+	l.m.Lock()
+	l.retries++
+	l.m.Unlock()
+}
+
+// Sitemap is called before Exit with the sitemap urls that were never linked, and the crawled pages missing from the
+// sitemap
+func (l *Logger) Sitemap(orphans, missing []string) {
+}
+
+// Exit is called when the queue has finished and the logger should finalise
+func (l *Logger) Exit() {
+}
+
+// errorType returns the label used to count an error
+func errorType(err error) string {
+	switch err {
+	case getter.ErrDisallowed:
+		return "disallowed"
+	case getter.ErrRedirectLoop:
+		return "redirect_loop"
+	case getter.ErrTooManyRedirects:
+		return "too_many_redirects"
+	case context.DeadlineExceeded:
+		return "timeout"
+	case context.Canceled:
+		return "canceled"
+	}
+	return "other"
+}
+
+// snapshot is a copy of the metrics, so they can be written without holding the lock
+type snapshot struct {
+	queued, started, retries, full uint64
+	finished, errors, cache        map[string]uint64
+	counts                         []uint64
+	sum                            float64
+	count                          uint64
+}
+
+// snapshot copies the metrics
+func (l *Logger) snapshot() snapshot {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return snapshot{
+		queued:   l.queued,
+		started:  l.started,
+		retries:  l.retries,
+		full:     l.full,
+		finished: copyCounts(l.finished),
+		errors:   copyCounts(l.errors),
+		cache:    copyCounts(l.cache),
+		counts:   append([]uint64(nil), l.counts...),
+		sum:      l.sum,
+		count:    l.count,
+	}
+}
+
+// copyCounts returns a copy of a map of counts
+func copyCounts(m map[string]uint64) map[string]uint64 {
+	c := make(map[string]uint64, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format. The metrics are copied first, so a slow client doesn't
+// hold up the crawl.
+func (l *Logger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.ensureInitialised()
+	s := l.snapshot()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	counter := func(name, help string, value uint64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+	}
+	labelled := func(name, help, label string, values map[string]uint64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		var keys []string
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s{%s=%q} %d\n", name, label, k, values[k])
+		}
+	}
+
+	counter("scrapy_queued_total", "Number of urls queued.", s.queued)
+	counter("scrapy_started_total", "Number of urls started.", s.started)
+	labelled("scrapy_finished_total", "Number of urls finished, by status code class.", "code", s.finished)
+	labelled("scrapy_errors_total", "Number of errors, by type.", "type", s.errors)
+	labelled("scrapy_cache_total", "Number of urls finished, by cache result.", "result", s.cache)
+	counter("scrapy_queue_full_total", "Number of urls dropped because the queue was full.", s.full)
+	counter("scrapy_retries_total", "Number of requests retried.", s.retries)
+
+	name := "scrapy_latency_seconds"
+	fmt.Fprintf(w, "# HELP %s Time taken to get and parse each url.\n# TYPE %s histogram\n", name, name)
+	var cumulative uint64
+	for i, bound := range l.Buckets {
+		cumulative += s.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, bound, cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, s.count)
+	fmt.Fprintf(w, "%s_sum %g\n", name, s.sum)
+	fmt.Fprintf(w, "%s_count %d\n", name, s.count)
+}
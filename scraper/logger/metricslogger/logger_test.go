@@ -0,0 +1,69 @@
+package metricslogger
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/logger"
+	"github.com/dave/scrapy/scraper/queuer"
+)
+
+func TestLogger(t *testing.T) {
+	l := &Logger{Buckets: []float64{0.1, 1}}
+	l.Init()
+	l.Queued("https://a/")
+	l.Queued("https://b/")
+	l.Starting("https://a/", 0)
+	l.Finished("https://a/", logger.Stats{Code: 200, Latency: 50 * time.Millisecond, Cache: "hit"})
+	l.Finished("https://b/", logger.Stats{Code: 404, Latency: 500 * time.Millisecond})
+	l.Finished("https://c/", logger.Stats{Code: 200, Latency: 2 * time.Second})
+	l.Error("https://d/", queuer.ErrDuplicate)
+	l.Error("https://d/", queuer.ErrFull)
+	l.Error("https://d/", getter.ErrDisallowed)
+	l.Error("https://d/", errors.New("a"))
+	l.Retrying("https://e/", 1, time.Second, errors.New("b"))
+
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	expected := `# HELP scrapy_queued_total Number of urls queued.
+# TYPE scrapy_queued_total counter
+scrapy_queued_total 2
+# HELP scrapy_started_total Number of urls started.
+# TYPE scrapy_started_total counter
+scrapy_started_total 1
+# HELP scrapy_finished_total Number of urls finished, by status code class.
+# TYPE scrapy_finished_total counter
+scrapy_finished_total{code="2xx"} 2
+scrapy_finished_total{code="4xx"} 1
+# HELP scrapy_errors_total Number of errors, by type.
+# TYPE scrapy_errors_total counter
+scrapy_errors_total{type="disallowed"} 1
+scrapy_errors_total{type="other"} 1
+# HELP scrapy_cache_total Number of urls finished, by cache result.
+# TYPE scrapy_cache_total counter
+scrapy_cache_total{result="hit"} 1
+# HELP scrapy_queue_full_total Number of urls dropped because the queue was full.
+# TYPE scrapy_queue_full_total counter
+scrapy_queue_full_total 1
+# HELP scrapy_retries_total Number of requests retried.
+# TYPE scrapy_retries_total counter
+scrapy_retries_total 1
+# HELP scrapy_latency_seconds Time taken to get and parse each url.
+# TYPE scrapy_latency_seconds histogram
+scrapy_latency_seconds_bucket{le="0.1"} 1
+scrapy_latency_seconds_bucket{le="1"} 2
+scrapy_latency_seconds_bucket{le="+Inf"} 3
+scrapy_latency_seconds_sum 2.55
+scrapy_latency_seconds_count 3
+`
+	if w.Body.String() != expected {
+		t.Errorf("expected:\n%s\nfound:\n%s", expected, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+}
@@ -3,8 +3,12 @@ package mocklogger
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/logger"
 )
 
 // Logger is a logger.Interface that stores a string representation of each logged event for testing
@@ -24,17 +28,17 @@ func (l *Logger) Queued(url string) {
 }
 
 // Starting is called each time a url starts processing
-func (l *Logger) Starting(url string) {
+func (l *Logger) Starting(url string, depth int) {
 	l.m.Lock()
 	defer l.m.Unlock()
-	l.Log = append(l.Log, fmt.Sprintf("start %s", url))
+	l.Log = append(l.Log, fmt.Sprintf("start %s%s", url, formatDepth(depth)))
 }
 
 // Finished is called each time a URL successfully finishes processing (even for non-200 results)
-func (l *Logger) Finished(url string, code int, latency time.Duration, urls, errors int) {
+func (l *Logger) Finished(url string, stats logger.Stats) {
 	l.m.Lock()
 	defer l.m.Unlock()
-	l.Log = append(l.Log, fmt.Sprintf("finish %s: %d, %d, %d", url, code, urls, errors))
+	l.Log = append(l.Log, fmt.Sprintf("finish %s%s%s: %d, %d, %d%s%s", url, formatDepth(stats.Depth), formatRedirect(url, stats.URL), stats.Code, stats.Urls, stats.Errors, formatTruncated(stats.Truncated), formatSkipped(stats.Skipped)))
 }
 
 // Error is called on every error
@@ -44,5 +48,63 @@ func (l *Logger) Error(url string, err error) {
 	l.Log = append(l.Log, fmt.Sprintf("error %s: %v", url, err))
 }
 
+// RedirectError is called instead of Error when a request fails after following redirects
+func (l *Logger) RedirectError(url string, redirects []getter.Redirect, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	var hops []string
+	for _, r := range redirects {
+		hops = append(hops, fmt.Sprintf("%s %d", r.URL, r.Code))
+	}
+	l.Log = append(l.Log, fmt.Sprintf("error %s (%s): %v", url, strings.Join(hops, ", "), err))
+}
+
+// Retrying is called each time a request fails and will be retried after the delay
+func (l *Logger) Retrying(url string, attempt int, delay time.Duration, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.Log = append(l.Log, fmt.Sprintf("retry %s (attempt %d, %v): %v", url, attempt, delay, err))
+}
+
+// Sitemap is called before Exit with the sitemap urls that were never linked, and the crawled pages missing from the
+// sitemap
+func (l *Logger) Sitemap(orphans, missing []string) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.Log = append(l.Log, fmt.Sprintf("sitemap orphans: %s; missing: %s", strings.Join(orphans, ", "), strings.Join(missing, ", ")))
+}
+
 // Exit is called when the queue has finished and the logger should finalise
 func (l *Logger) Exit() {}
+
+// formatRedirect only shows the final url when the page was redirected
+func formatRedirect(url, final string) string {
+	if final == "" || final == url {
+		return ""
+	}
+	return fmt.Sprintf(" -> %s", final)
+}
+
+// formatTruncated only shows truncation when the body was truncated
+func formatTruncated(truncated bool) string {
+	if !truncated {
+		return ""
+	}
+	return " (truncated)"
+}
+
+// formatSkipped only shows that the body was skipped when it was
+func formatSkipped(skipped bool) string {
+	if !skipped {
+		return ""
+	}
+	return " (skipped)"
+}
+
+// formatDepth only shows the depth when it's not zero, so the log for the start url is kept short
+func formatDepth(depth int) string {
+	if depth == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (depth %d)", depth)
+}
@@ -0,0 +1,191 @@
+// Package multilogger defines a logger.Interface that forwards every event to several other loggers
+package multilogger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/logger"
+)
+
+// DefaultBuffer is the default number of events buffered for each logger
+const DefaultBuffer = 1000
+
+// Logger is a logger.Interface that forwards every event to several other loggers. Each logger receives events from
+// its own buffered queue in a separate goroutine, so a slow logger doesn't hold up the crawl or the other loggers:
+// events are dropped when a queue is full, and panics are recovered. Init and Exit are called on each logger in order,
+// and the sitemap report is delivered before Exit so it is never dropped.
+type Logger struct {
+	Loggers []logger.Interface     // The loggers to forward events to
+	Buffer  int                    // Number of events buffered for each logger (default DefaultBuffer)
+	sinks   []*sink                // A sink for each logger
+	closed  bool                   // Has Exit been called?
+	report  func(logger.Interface) // The sitemap report, delivered by Exit
+	m       sync.RWMutex           // Protects closed and report, so events aren't sent after the queues are closed
+	once    sync.Once              // For initialisation
+}
+
+// sink delivers events to a logger
+type sink struct {
+	logger  logger.Interface
+	events  chan func(logger.Interface) // pending events
+	done    chan struct{}               // closed when all events have been delivered
+	dropped uint64                      // events dropped because the buffer was full
+	panics  uint64                      // events that caused the logger to panic
+}
+
+// initialises the sinks
+func (l *Logger) ensureInitialised() {
+	l.once.Do(func() {
+		if l.Buffer == 0 {
+			l.Buffer = DefaultBuffer
+		}
+		for _, lg := range l.Loggers {
+			l.sinks = append(l.sinks, &sink{
+				logger: lg,
+				events: make(chan func(logger.Interface), l.Buffer),
+				done:   make(chan struct{}),
+			})
+		}
+	})
+}
+
+// Init initialises each logger in order and starts delivering events
+func (l *Logger) Init() {
+	l.ensureInitialised()
+	for _, s := range l.sinks {
+		s.call(logger.Interface.Init)
+		go s.run()
+	}
+}
+
+// Queued is called each time a url is successfully queued
+func (l *Logger) Queued(url string) {
+	l.send(func(lg logger.Interface) { lg.Queued(url) })
+}
+
+// Starting is called each time a url starts processing
+func (l *Logger) Starting(url string, depth int) {
+	l.send(func(lg logger.Interface) { lg.Starting(url, depth) })
+}
+
+// Finished is called each time a URL successfully finishes processing (even for non-200 results)
+func (l *Logger) Finished(url string, stats logger.Stats) {
+	l.send(func(lg logger.Interface) { lg.Finished(url, stats) })
+}
+
+// Error is called on every error
+func (l *Logger) Error(url string, err error) {
+	l.send(func(lg logger.Interface) { lg.Error(url, err) })
+}
+
+// RedirectError is called instead of Error when a request fails after following redirects. Loggers that don't report
+// redirects are sent the error.
+func (l *Logger) RedirectError(url string, redirects []getter.Redirect, err error) {
+	l.send(func(lg logger.Interface) {
+		if rl, ok := lg.(logger.Redirecter); ok {
+			rl.RedirectError(url, redirects, err)
+			return
+		}
+		lg.Error(url, err)
+	})
+}
+
+// Retrying is called each time a request fails and will be retried after the delay
+func (l *Logger) Retrying(url string, attempt int, delay time.Duration, err error) {
+	l.send(func(lg logger.Interface) { lg.Retrying(url, attempt, delay, err) })
+}
+
+// Sitemap is called before Exit with the sitemap urls that were never linked, and the crawled pages missing from the
+// sitemap. The report is only sent once, so it is delivered by Exit after the pending events rather than being dropped.
+func (l *Logger) Sitemap(orphans, missing []string) {
+	l.ensureInitialised()
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.closed {
+		return
+	}
+	l.report = func(lg logger.Interface) { lg.Sitemap(orphans, missing) }
+}
+
+// Exit waits for the pending events to be delivered to every logger, then delivers the sitemap report and calls Exit on
+// each logger in order
+func (l *Logger) Exit() {
+	l.ensureInitialised()
+	l.m.Lock()
+	if l.closed {
+		l.m.Unlock()
+		return
+	}
+	l.closed = true
+	for _, s := range l.sinks {
+		close(s.events)
+	}
+	l.m.Unlock()
+	for _, s := range l.sinks {
+		<-s.done
+	}
+	for _, s := range l.sinks {
+		if l.report != nil {
+			s.call(l.report)
+		}
+		s.call(logger.Interface.Exit)
+	}
+}
+
+// Dropped returns the number of events dropped for each logger because its buffer was full
+func (l *Logger) Dropped() []uint64 {
+	l.ensureInitialised()
+	dropped := make([]uint64, len(l.sinks))
+	for i, s := range l.sinks {
+		dropped[i] = atomic.LoadUint64(&s.dropped)
+	}
+	return dropped
+}
+
+// Panics returns the number of events that caused each logger to panic
+func (l *Logger) Panics() []uint64 {
+	l.ensureInitialised()
+	panics := make([]uint64, len(l.sinks))
+	for i, s := range l.sinks {
+		panics[i] = atomic.LoadUint64(&s.panics)
+	}
+	return panics
+}
+
+// send queues an event for each logger, or drops it if the buffer is full
+func (l *Logger) send(event func(logger.Interface)) {
+	l.ensureInitialised()
+	l.m.RLock()
+	defer l.m.RUnlock()
+	if l.closed {
+		return
+	}
+	for _, s := range l.sinks {
+		select {
+		case s.events <- event:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// run delivers events to the logger until the queue is closed
+func (s *sink) run() {
+	defer close(s.done)
+	for event := range s.events {
+		s.call(event)
+	}
+}
+
+// call delivers an event to the logger, recovering from panics
+func (s *sink) call(event func(logger.Interface)) {
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddUint64(&s.panics, 1)
+		}
+	}()
+	event(s.logger)
+}
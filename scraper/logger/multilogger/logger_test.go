@@ -0,0 +1,113 @@
+package multilogger
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dave/scrapy/scraper/logger"
+)
+
+// testLogger records the events it receives, can be blocked until released, and panics on Error
+type testLogger struct {
+	name    string
+	events  *[]string
+	m       *sync.Mutex
+	blocked chan struct{} // if not nil, Queued sends to this and then waits until release is closed
+	release chan struct{}
+}
+
+func (l *testLogger) log(event string) {
+	l.m.Lock()
+	*l.events = append(*l.events, l.name+" "+event)
+	l.m.Unlock()
+}
+
+func (l *testLogger) Init()                                   { l.log("init") }
+func (l *testLogger) Starting(url string, depth int)          { l.log("starting " + url) }
+func (l *testLogger) Finished(url string, stats logger.Stats) { l.log("finished " + url) }
+func (l *testLogger) Error(url string, err error)             { panic(err) }
+func (l *testLogger) Retrying(url string, attempt int, delay time.Duration, err error) {
+	l.log("retrying " + url)
+}
+func (l *testLogger) Sitemap(orphans, missing []string) { l.log(fmt.Sprint("sitemap ", orphans)) }
+func (l *testLogger) Exit()                             { l.log("exit") }
+func (l *testLogger) Queued(url string) {
+	if l.blocked != nil {
+		l.blocked <- struct{}{}
+		<-l.release
+	}
+	l.log("queued " + url)
+}
+
+func TestLogger(t *testing.T) {
+	var events []string
+	m := &sync.Mutex{}
+	blocked, release := make(chan struct{}), make(chan struct{})
+	a := &testLogger{name: "a", events: &events, m: m}
+	b := &testLogger{name: "b", events: &events, m: m, blocked: blocked, release: release}
+	l := &Logger{Loggers: []logger.Interface{a, b}, Buffer: 1}
+	l.Init()
+
+	// a receives every event. b is blocked on the first event, so only one more fits in its buffer and the rest are
+	// dropped. The sitemap report isn't dropped, and doesn't block: it is delivered by Exit.
+	l.Queued("https://a/")
+	waitFor(t, m, &events, "a queued https://a/")
+	<-blocked
+	l.Starting("https://a/", 0)
+	waitFor(t, m, &events, "a starting https://a/")
+	l.Finished("https://a/", logger.Stats{})
+	waitFor(t, m, &events, "a finished https://a/")
+	l.Sitemap([]string{"https://b/"}, nil)
+
+	if expected := []uint64{0, 1}; !reflect.DeepEqual(l.Dropped(), expected) {
+		t.Errorf("expected %v dropped, found %v", expected, l.Dropped())
+	}
+
+	// Error panics in both loggers, which is recovered
+	close(release)
+	waitFor(t, m, &events, "b starting https://a/")
+	l.Error("https://c/", fmt.Errorf("c"))
+	l.Exit()
+
+	if expected := []uint64{1, 1}; !reflect.DeepEqual(l.Panics(), expected) {
+		t.Errorf("expected %v panics, found %v", expected, l.Panics())
+	}
+
+	// Events sent after Exit are ignored
+	l.Queued("https://d/")
+
+	expected := []string{
+		"a init",
+		"b init",
+		"a queued https://a/",
+		"a starting https://a/",
+		"a finished https://a/",
+		"b queued https://a/",
+		"b starting https://a/",
+		"a sitemap [https://b/]",
+		"a exit",
+		"b sitemap [https://b/]",
+		"b exit",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %#v, found %#v", expected, events)
+	}
+}
+
+// waitFor waits until the last event is the expected one
+func waitFor(t *testing.T, m *sync.Mutex, events *[]string, expected string) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		m.Lock()
+		found := len(*events) > 0 && (*events)[len(*events)-1] == expected
+		m.Unlock()
+		if found {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %q", expected)
+}
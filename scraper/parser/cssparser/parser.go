@@ -0,0 +1,63 @@
+// Package cssparser defines a parser.Interface that parses CSS files and returns the urls from url(...) functions and
+// @import rules
+package cssparser
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/dave/scrapy/scraper/canonical"
+	"github.com/dave/scrapy/scraper/parser"
+)
+
+// Parser is a parser.Interface that parses CSS files and returns the urls from url(...) functions and @import rules
+type Parser struct {
+	Include   func(*url.URL) bool // Include filters the urls - return false to exclude a url
+	Canonical *canonical.Rules    // Rules used to convert urls to canonical form (if nil, canonical.Default is used)
+}
+
+// Parse parses the css and returns the links and parse errors
+func (p *Parser) Parse(ctx context.Context, urlPage, charset string, body io.Reader) (result parser.Result) {
+	page, err := url.Parse(urlPage)
+	if err != nil {
+		return parser.Result{Errs: []error{err}}
+	}
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return parser.Result{Errs: []error{err}}
+	}
+
+	for _, raw := range URLs(string(b)) {
+		u, err := parser.Resolve(page, raw, p.Canonical, p.Include)
+		if err != nil {
+			result.Errs = append(result.Errs, err)
+			continue
+		}
+		if u == nil {
+			continue
+		}
+		result.Links = append(result.Links, parser.Link{URL: u.String(), Kind: parser.KindCSS})
+	}
+	return result
+}
+
+// cssURL matches url(...) functions in css, with double quoted, single quoted or unquoted arguments, and @import rules
+// with a quoted url
+var cssURL = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^'")\s]*))\s*\)|@import\s+(?:"([^"]*)"|'([^']*)')`)
+
+// URLs returns the urls from url(...) functions and @import rules in css. Data urls are skipped.
+func URLs(css string) (urls []string) {
+	for _, match := range cssURL.FindAllStringSubmatch(css, -1) {
+		// Only one of the groups will match
+		raw := strings.Join(match[1:], "")
+		if raw != "" && !strings.HasPrefix(raw, "data:") {
+			urls = append(urls, raw)
+		}
+	}
+	return urls
+}
@@ -0,0 +1,52 @@
+package cssparser
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dave/scrapy/scraper/parser"
+)
+
+func TestURLs(t *testing.T) {
+	tests := []struct {
+		name     string
+		css      string
+		expected []string
+	}{
+		{
+			name:     "quotes",
+			css:      `a { background: url("a") } b { background: url('b') } c { background: url( c ) }`,
+			expected: []string{"a", "b", "c"},
+		},
+		{
+			name:     "import",
+			css:      `@import "a.css"; @import 'b.css'; @import url(c.css);`,
+			expected: []string{"a.css", "b.css", "c.css"},
+		},
+		{
+			name: "data",
+			css:  `a { background: url(data:x) }`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if urls := URLs(test.css); !reflect.DeepEqual(urls, test.expected) {
+				t.Errorf("unexpected urls %#v", urls)
+			}
+		})
+	}
+}
+
+func TestParser(t *testing.T) {
+	p := &Parser{}
+	result := p.Parse(context.Background(), "https://a/css/main.css", "", strings.NewReader(`@import "b.css"; a { background: url(/img/c.png#d) }`))
+	expected := []parser.Link{
+		{URL: "https://a/css/b.css", Kind: parser.KindCSS},
+		{URL: "https://a/img/c.png", Kind: parser.KindCSS},
+	}
+	if !reflect.DeepEqual(result.Links, expected) {
+		t.Errorf("unexpected links %#v", result.Links)
+	}
+}
@@ -1,132 +1,350 @@
-// Package htmlparser defines a parser.Interface that parses HTML and returns the urls from anchor href attributes
+// Package htmlparser defines a parser.Interface that parses HTML and returns the urls from anchor href attributes, and
+// optionally other kinds of reference
 package htmlparser
 
 import (
+	"bufio"
 	"context"
 	"io"
 	"net/url"
-	"path"
 	"strings"
 
+	"github.com/dave/scrapy/scraper/canonical"
+	"github.com/dave/scrapy/scraper/parser"
+	"github.com/dave/scrapy/scraper/parser/cssparser"
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/transform"
 )
 
-// Parser is a parser.Interface that parses HTML and returns the urls from anchor href attributes
+// Parser is a parser.Interface that parses HTML and returns the urls from anchor href attributes, and optionally other
+// kinds of reference
 type Parser struct {
-	Include func(*url.URL) bool
+	Include   func(*url.URL) bool // Include filters the urls - return false to exclude a url
+	Kinds     []parser.Kind       // Kinds of reference to extract (if nil, only anchors are extracted)
+	Nofollow  bool                // Suppress links with rel="nofollow", and all links on pages with meta robots nofollow
+	Canonical *canonical.Rules    // Rules used to convert urls to canonical form (if nil, canonical.Default is used)
 }
 
-// Parse parses the document and returns the urls and parse errors
-func (p *Parser) Parse(ctx context.Context, urlPage string, body io.Reader) (urls []string, errs []error) {
-	t := html.NewTokenizer(body)
+// reference is a raw url found in the document, before it has been normalised
+type reference struct {
+	raw      string
+	kind     parser.Kind
+	nofollow bool // the tag has rel="nofollow"
+}
 
+// Parse parses the document and returns the links and parse errors
+func (p *Parser) Parse(ctx context.Context, urlPage, charset string, body io.Reader) (result parser.Result) {
 	page, err := url.Parse(urlPage)
 	if err != nil {
-		return nil, []error{err}
+		return parser.Result{Errs: []error{err}}
+	}
+
+	// Convert the document to UTF-8 before tokenising
+	body, result.Encoding = decode(body, charset)
+	t := html.NewTokenizer(body)
+
+	// Relative urls are resolved against the base url, which can be changed by a <base href> element
+	base := page
+	var baseFound bool
+
+	// Does the page have <meta name="robots" content="nofollow">?
+	var metaNofollow bool
+
+	kinds := map[parser.Kind]bool{parser.KindAnchor: true}
+	if p.Kinds != nil {
+		kinds = map[parser.Kind]bool{}
+		for _, k := range p.Kinds {
+			kinds[k] = true
+		}
+	}
+
+	rules := p.Canonical
+	if rules == nil {
+		rules = canonical.Default
+	}
+
+	var inStyle bool // Are we inside a <style> element?
+
+	// The text of the current <a> element is collected until the end tag, then set on the links it contains
+	var inAnchor bool
+	var anchorStart, excludedStart int // indexes in result.Links and result.Excluded of the first links from the current <a> element
+	var anchorText string
+	closeAnchor := func() {
+		if !inAnchor {
+			return
+		}
+		inAnchor = false
+		text := strings.Join(strings.Fields(anchorText), " ")
+		setText(result.Links[anchorStart:], text)
+		setText(result.Excluded[excludedStart:], text)
 	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			return nil, []error{ctx.Err()}
+			return parser.Result{Errs: []error{ctx.Err()}}
 		default:
 			// great!
 		}
+		var refs []reference
 		typ := t.Next()
 		switch typ {
 		case html.ErrorToken:
 
 			// End of document
 			if t.Err() == io.EOF {
+				closeAnchor()
+				if metaNofollow && p.Nofollow {
+					// The meta tag may come after some links, so they are suppressed at the end
+					suppress(&result, parser.MetaNofollow, len(result.Links))
+					result.Links = nil
+				}
 				return
 			}
 
 			// Log a parser error
-			errs = append(errs, t.Err())
+			result.Errs = append(result.Errs, t.Err())
 			continue
 
-		case html.StartTagToken:
+		case html.StartTagToken, html.SelfClosingTagToken:
 			tok := t.Token()
+			inStyle = typ == html.StartTagToken && tok.Data == "style"
+			refs = references(tok)
 
-			// Look for a tags.
-			// TODO: Look for more tags?
-			if tok.Data != "a" {
-				continue
+			switch tok.Data {
+			case "a":
+				// Anchors can't be nested, so a new <a> closes the previous one
+				closeAnchor()
+				inAnchor = typ == html.StartTagToken
+				anchorStart = len(result.Links)
+				excludedStart = len(result.Excluded)
+				anchorText = ""
+			case "img":
+				// Images in anchors are described by their alt text
+				if alt, ok := attr(tok, "alt"); ok && inAnchor {
+					anchorText += " " + alt + " "
+				}
+			case "base":
+				// Only the first <base href> is used
+				if href, ok := attr(tok, "href"); ok && !baseFound {
+					baseFound = true
+					u, err := page.Parse(href)
+					if err != nil {
+						result.Errs = append(result.Errs, err)
+						break
+					}
+					base = u
+				}
+			case "meta":
+				// Noindex is always reported, but nofollow only suppresses links if Nofollow is set
+				if name, _ := attr(tok, "name"); strings.EqualFold(name, "robots") {
+					content, _ := attr(tok, "content")
+					for _, directive := range strings.Split(strings.ToLower(content), ",") {
+						switch strings.TrimSpace(directive) {
+						case "nofollow":
+							metaNofollow = true
+						case "noindex":
+							result.NoIndex = true
+						case "none":
+							metaNofollow = true
+							result.NoIndex = true
+						}
+					}
+				}
 			}
 
-			for _, att := range tok.Attr {
+		case html.EndTagToken:
+			inStyle = false
+			if t.Token().Data == "a" {
+				closeAnchor()
+			}
 
-				// Look for href attributes.
-				// TODO: Look for more attributes?
-				if att.Key != "href" {
-					continue
+		case html.TextToken:
+			text := string(t.Text())
+			if inAnchor {
+				anchorText += text
+			}
+			// The contents of <style> elements are returned as a single text token
+			if inStyle {
+				for _, raw := range cssparser.URLs(text) {
+					refs = append(refs, reference{raw: raw, kind: parser.KindCSS})
 				}
+			}
+		}
 
-				u, err := normalise(att.Val, page)
-				if err != nil {
-					errs = append(errs, err)
-					break
-				}
-				if u == nil {
-					break
-				}
+		for _, ref := range refs {
+			if !kinds[ref.kind] {
+				continue
+			}
 
-				// Run the include function if it exists and skip this url if needed
-				if p.Include != nil && !p.Include(u) {
-					break
-				}
+			u, err := normalise(ref.raw, base, ref.kind, rules)
+			if err != nil {
+				result.Errs = append(result.Errs, err)
+				continue
+			}
+			if u == nil {
+				continue
+			}
 
-				// Stop scanning attributes once we've found a url
-				urls = append(urls, u.String())
-				break
+			// Run the include function if it exists and skip this url if needed
+			if p.Include != nil && !p.Include(u) {
+				result.Excluded = append(result.Excluded, parser.Link{URL: u.String(), Kind: ref.kind})
+				continue
 			}
+
+			if p.Nofollow && ref.nofollow {
+				suppress(&result, parser.RelNofollow, 1)
+				continue
+			}
+
+			result.Links = append(result.Links, parser.Link{URL: u.String(), Kind: ref.kind})
 		}
 	}
 }
 
-// normalise performs modifications on a url in order to reduce duplicates and errors
-func normalise(raw string, page *url.URL) (*url.URL, error) {
+// references returns the raw urls found in the attributes of a tag
+func references(tok html.Token) (refs []reference) {
+	rel, _ := attr(tok, "rel")
+	var nofollow bool
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		if r == "nofollow" {
+			nofollow = true
+		}
+	}
+	add := func(raw string, kind parser.Kind) {
+		refs = append(refs, reference{raw: raw, kind: kind, nofollow: nofollow})
+	}
+	for _, att := range tok.Attr {
+		switch {
+		case att.Key == "style":
+			for _, raw := range cssparser.URLs(att.Val) {
+				add(raw, parser.KindCSS)
+			}
+		case tok.Data == "a" && att.Key == "href":
+			add(att.Val, parser.KindAnchor)
+		case tok.Data == "area" && att.Key == "href":
+			add(att.Val, parser.KindArea)
+		case tok.Data == "link" && att.Key == "href":
+			add(att.Val, parser.KindLink)
+		case tok.Data == "img" && att.Key == "src":
+			add(att.Val, parser.KindImage)
+		case tok.Data == "img" && att.Key == "srcset":
+			for _, raw := range srcset(att.Val) {
+				add(raw, parser.KindImage)
+			}
+		case tok.Data == "script" && att.Key == "src":
+			add(att.Val, parser.KindScript)
+		case tok.Data == "iframe" && att.Key == "src":
+			add(att.Val, parser.KindIframe)
+		case tok.Data == "form" && att.Key == "action":
+			add(att.Val, parser.KindForm)
+		case tok.Data == "meta" && att.Key == "content" && isRefresh(tok):
+			if raw := refreshURL(att.Val); raw != "" {
+				add(raw, parser.KindRefresh)
+			}
+		}
+	}
+	return refs
+}
 
-	// Let's throw away a common errors
-	if strings.HasPrefix(raw, "tel:") || strings.HasPrefix(raw, "mailto:") || strings.HasPrefix(raw, "javascript:") {
-		return nil, nil
+// setText sets the anchor text of the anchor links
+func setText(links []parser.Link, text string) {
+	for i := range links {
+		if links[i].Kind == parser.KindAnchor {
+			links[i].Text = text
+		}
 	}
+}
 
-	// URLs we don't want to get
-	if strings.HasSuffix(raw, ".zip") || strings.HasSuffix(raw, ".pdf") || strings.HasSuffix(raw, ".png") || strings.HasSuffix(raw, ".jpg") {
-		return nil, nil
+// isRefresh returns true if the tag is <meta http-equiv="refresh">
+func isRefresh(tok html.Token) bool {
+	value, _ := attr(tok, "http-equiv")
+	return strings.EqualFold(strings.TrimSpace(value), "refresh")
+}
+
+// attr returns the value of an attribute of a tag
+func attr(tok html.Token, key string) (string, bool) {
+	for _, att := range tok.Attr {
+		if att.Key == key {
+			return att.Val, true
+		}
 	}
+	return "", false
+}
 
-	u, err := url.Parse(raw)
-	if err != nil {
-		return nil, err
+// refreshURL returns the url from the content of a meta refresh tag - e.g. "5; url=/foo"
+func refreshURL(content string) string {
+	i := strings.Index(content, ";")
+	if i == -1 {
+		return ""
+	}
+	value := strings.TrimSpace(content[i+1:])
+	if len(value) < 4 || !strings.EqualFold(value[:4], "url=") {
+		return ""
 	}
+	return strings.Trim(strings.TrimSpace(value[4:]), `"'`)
+}
 
-	// Add scheme and host from page for relative URLs
-	if u.Scheme == "" {
-		u.Scheme = page.Scheme
+// srcset returns the urls from a srcset attribute - e.g. "a.jpg 1x, b.jpg 2x"
+func srcset(value string) (urls []string) {
+	for _, candidate := range strings.Split(value, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
 	}
-	if u.Host == "" {
-		u.Host = page.Host
+	return urls
+}
+
+// decode detects the character encoding of the document from the byte order mark, the charset from the Content-Type
+// header and <meta charset> tags (in that order), and returns a reader that converts it to UTF-8
+func decode(body io.Reader, label string) (io.Reader, string) {
+	contentType := "text/html"
+	if label != "" {
+		contentType += "; charset=" + label
 	}
 
-	// Handle relative paths - e.g. "../foo/bar.html"
-	if !path.IsAbs(u.Path) {
-		u.Path = path.Join(page.Path, u.Path)
+	// The html spec says the <meta charset> tag must be in the first 1024 bytes
+	r := bufio.NewReaderSize(body, 1024)
+	start, _ := r.Peek(1024)
+	enc, name, _ := charset.DetermineEncoding(start, contentType)
+	if name == "utf-8" {
+		return r, name
 	}
+	return transform.NewReader(r, enc.NewDecoder()), name
+}
 
-	// Normalize URLs to remove trailing slashes
-	if strings.HasSuffix(u.Path, "/") {
-		u.Path = u.Path[:len(u.Path)-1]
+// suppress counts links that were suppressed by the nofollow rules
+func suppress(result *parser.Result, reason string, count int) {
+	if count == 0 {
+		return
+	}
+	if result.Suppressed == nil {
+		result.Suppressed = map[string]int{}
 	}
+	result.Suppressed[reason] += count
+}
+
+// normalise resolves a url against the page url and converts it to canonical form, in order to reduce duplicates and
+// errors
+func normalise(raw string, page *url.URL, kind parser.Kind, rules *canonical.Rules) (*url.URL, error) {
 
-	// Prevent https page from linking to non-https version?
-	if page.Scheme == "https" && u.Scheme == "http" {
-		u.Scheme = "https"
+	// Let's throw away a common errors
+	if strings.HasPrefix(raw, "tel:") || strings.HasPrefix(raw, "mailto:") || strings.HasPrefix(raw, "javascript:") {
+		return nil, nil
 	}
 
-	// Clear path fragment to reduce duplication
-	u.Fragment = ""
+	// Links to pages that we don't want to get (other kinds of reference are assets, so we do want them)
+	if kind == parser.KindAnchor {
+		if strings.HasSuffix(raw, ".zip") || strings.HasSuffix(raw, ".pdf") || strings.HasSuffix(raw, ".png") || strings.HasSuffix(raw, ".jpg") {
+			return nil, nil
+		}
+	}
+
+	u, err := canonical.Resolve(page, raw)
+	if err != nil {
+		return nil, err
+	}
 
-	return u, nil
+	return rules.URL(u), nil
 }
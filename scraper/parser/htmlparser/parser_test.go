@@ -8,6 +8,9 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/dave/scrapy/scraper/canonical"
+	"github.com/dave/scrapy/scraper/parser"
 )
 
 func TestNormalise(t *testing.T) {
@@ -17,7 +20,7 @@ func TestNormalise(t *testing.T) {
 		{
 			name:     "simple",
 			url:      "https://a",
-			expected: "https://a",
+			expected: "https://a/",
 		},
 		{
 			name:     "throw away tel",
@@ -54,18 +57,41 @@ func TestNormalise(t *testing.T) {
 			name:     "relative path",
 			url:      "./../../e",
 			page:     "https://a/b/c/d",
+			expected: "https://a/e",
+		},
+		{
+			name:     "relative to file",
+			url:      "e",
+			page:     "https://a/b/c",
 			expected: "https://a/b/e",
 		},
+		{
+			name:     "relative to directory",
+			url:      "e",
+			page:     "https://a/b/c/",
+			expected: "https://a/b/c/e",
+		},
+		{
+			name:     "query only",
+			url:      "?page=2",
+			page:     "https://a/docs/page?page=1",
+			expected: "https://a/docs/page?page=2",
+		},
+		{
+			name:     "lowercase host and drop default port",
+			url:      "https://A.com:443/B",
+			expected: "https://a.com/B",
+		},
 		{
 			name:     "remove trailing slash",
 			url:      "https://a/b/",
 			expected: "https://a/b",
 		},
 		{
-			name:     "prevent jumping from https to http",
+			name:     "keep http link from https page",
 			url:      "http://a/b",
 			page:     "https://a",
-			expected: "https://a/b",
+			expected: "http://a/b",
 		},
 	}
 	for _, test := range tests {
@@ -74,7 +100,7 @@ func TestNormalise(t *testing.T) {
 			if err != nil {
 				t.Fatal("parsing page url failed")
 			}
-			out, err := normalise(test.url, page)
+			out, err := normalise(test.url, page, parser.KindAnchor, canonical.Default)
 			if test.err == "" && err != nil {
 				t.Errorf("expected no error but got %v", err)
 			}
@@ -93,16 +119,23 @@ func TestNormalise(t *testing.T) {
 
 func TestParser(t *testing.T) {
 	tests := []struct {
-		name string
-		body string
-		urls []string
-		errs []string
-		inc  func(url *url.URL) bool
+		name       string
+		page       string
+		body       string
+		urls       []string
+		errs       []string
+		inc        func(url *url.URL) bool
+		kinds      []parser.Kind
+		links      []parser.Link
+		excluded   []parser.Link
+		nofollow   bool
+		suppressed map[string]int
+		noindex    bool
 	}{
 		{
 			name: "simple",
 			body: `<a href="a"></a>`,
-			urls: []string{"a"},
+			urls: []string{"/a"},
 		},
 		{
 			name: "url error",
@@ -112,24 +145,123 @@ func TestParser(t *testing.T) {
 		{
 			name: "complex html",
 			body: `<body><p><a href="a"></a></p><table><td><a href="b"></a></td></table><!--<a href="c"></a>--></body>`,
-			urls: []string{"a", "b"},
+			urls: []string{"/a", "/b"},
 		},
 		{
 			name: "html and errors",
 			body: `<body><a href=":"></a><p><a href="a"></a></p><div><a href="b"></a><a href="1:2"></a></div></body>`,
-			urls: []string{"a", "b"},
+			urls: []string{"/a", "/b"},
 			errs: []string{"parse :: missing protocol scheme", "parse 1:2: first path segment in URL cannot contain colon"},
 		},
 		{
 			name: "include function",
-			body: `<a href="http://a.com/a"></a><a href="http://b.com/b"></a>`,
+			body: `<a href="http://a.com/a">A</a><a href="http://b.com/b"></a>`,
 			inc:  func(url *url.URL) bool { return url != nil && url.Host == "b.com" },
 			urls: []string{"http://b.com/b"},
+			excluded: []parser.Link{
+				{URL: "http://a.com/a", Kind: parser.KindAnchor, Text: "A"},
+			},
+		},
+		{
+			name: "only anchors by default",
+			body: `<a href="a"></a><img src="b"><script src="c"></script>`,
+			urls: []string{"/a"},
+		},
+		{
+			name:  "all kinds",
+			kinds: parser.Kinds,
+			body: `<html><head>
+				<meta http-equiv="Refresh" content="5; URL='a'">
+				<link rel="stylesheet" href="b">
+				<style>body { background: url("c") } div { background: url(data:x) }</style>
+				<script src="d"></script>
+			</head><body>
+				<a href="e" style="background: url( 'f' )"></a>
+				<img src="g.png" srcset="h.png 1x, i.png 2x"/>
+				<iframe src="j"></iframe>
+				<form action="k"></form>
+				<map><area href="l"></map>
+			</body></html>`,
+			links: []parser.Link{
+				{URL: "/a", Kind: parser.KindRefresh},
+				{URL: "/b", Kind: parser.KindLink},
+				{URL: "/c", Kind: parser.KindCSS},
+				{URL: "/d", Kind: parser.KindScript},
+				{URL: "/e", Kind: parser.KindAnchor},
+				{URL: "/f", Kind: parser.KindCSS},
+				{URL: "/g.png", Kind: parser.KindImage},
+				{URL: "/h.png", Kind: parser.KindImage},
+				{URL: "/i.png", Kind: parser.KindImage},
+				{URL: "/j", Kind: parser.KindIframe},
+				{URL: "/k", Kind: parser.KindForm},
+				{URL: "/l", Kind: parser.KindArea},
+			},
+		},
+		{
+			name: "anchor text",
+			body: `<a href="a"> Hello, <b>wor</b>ld
+				</a><a href="b"><img src="c.png" alt="Logo"></a><a href="d">unclosed<a href="e"></a>`,
+			links: []parser.Link{
+				{URL: "/a", Kind: parser.KindAnchor, Text: "Hello, world"},
+				{URL: "/b", Kind: parser.KindAnchor, Text: "Logo"},
+				{URL: "/d", Kind: parser.KindAnchor, Text: "unclosed"},
+				{URL: "/e", Kind: parser.KindAnchor},
+			},
+		},
+		{
+			name:  "selected kinds",
+			kinds: []parser.Kind{parser.KindImage},
+			body:  `<a href="a"></a><img src="b.jpg">`,
+			links: []parser.Link{{URL: "/b.jpg", Kind: parser.KindImage}},
+		},
+		{
+			name: "base href",
+			page: "https://a/b/c",
+			body: `<head><base href="/d/"><base href="/e/"></head><a href="f"></a><a href="/g"></a>`,
+			urls: []string{"https://a/d/f", "https://a/g"},
+		},
+		{
+			name: "rel nofollow ignored",
+			body: `<a href="a" rel="nofollow"></a><a href="b"></a>`,
+			urls: []string{"/a", "/b"},
+		},
+		{
+			name:       "rel nofollow",
+			nofollow:   true,
+			body:       `<a href="a" rel="external NoFollow"></a><a href="b"></a>`,
+			urls:       []string{"/b"},
+			suppressed: map[string]int{parser.RelNofollow: 1},
+		},
+		{
+			name:       "meta nofollow",
+			nofollow:   true,
+			body:       `<a href="a"></a><meta name="robots" content="nofollow"><a href="b" rel="nofollow"></a><a href="c"></a>`,
+			suppressed: map[string]int{parser.RelNofollow: 1, parser.MetaNofollow: 2},
+		},
+		{
+			name:     "meta noindex",
+			nofollow: true,
+			body:     `<meta name="ROBOTS" content="noindex, follow"><a href="a"></a>`,
+			urls:     []string{"/a"},
+			noindex:  true,
+		},
+		{
+			name:    "meta noindex without nofollow",
+			body:    `<meta name="robots" content="none"><a href="a"></a>`,
+			urls:    []string{"/a"},
+			noindex: true,
+		},
+		{
+			name:       "meta none",
+			nofollow:   true,
+			body:       `<meta name="robots" content="none"><a href="a"></a>`,
+			suppressed: map[string]int{parser.MetaNofollow: 1},
+			noindex:    true,
 		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			p := &Parser{}
+			p := &Parser{Kinds: test.kinds, Nofollow: test.nofollow}
 
 			if test.inc != nil {
 				p.Include = test.inc
@@ -137,10 +269,32 @@ func TestParser(t *testing.T) {
 
 			body := ioutil.NopCloser(bytes.NewBufferString(test.body))
 
-			urls, errs := p.Parse(context.Background(), "", body)
+			result := p.Parse(context.Background(), test.page, "", body)
+			links, errs := result.Links, result.Errs
+
+			if !reflect.DeepEqual(result.Suppressed, test.suppressed) {
+				t.Errorf("unexpected suppressed - got: %#v, expected: %#v", result.Suppressed, test.suppressed)
+			}
+			if result.NoIndex != test.noindex {
+				t.Errorf("unexpected noindex - got: %v, expected: %v", result.NoIndex, test.noindex)
+			}
+
+			if test.excluded != nil && !reflect.DeepEqual(result.Excluded, test.excluded) {
+				t.Errorf("unexpected excluded - got: %#v, expected: %#v", result.Excluded, test.excluded)
+			}
 
-			if !reflect.DeepEqual(urls, test.urls) {
-				t.Errorf("unexpected urls - got: %#v, expected: %#v", urls, test.urls)
+			if test.links != nil {
+				if !reflect.DeepEqual(links, test.links) {
+					t.Errorf("unexpected links - got: %#v, expected: %#v", links, test.links)
+				}
+			} else {
+				var urls []string
+				for _, l := range links {
+					urls = append(urls, l.URL)
+				}
+				if !reflect.DeepEqual(urls, test.urls) {
+					t.Errorf("unexpected urls - got: %#v, expected: %#v", urls, test.urls)
+				}
 			}
 			var errorStrings []string
 			for _, e := range errs {
@@ -152,3 +306,55 @@ func TestParser(t *testing.T) {
 		})
 	}
 }
+
+func TestParser_encoding(t *testing.T) {
+	tests := []struct {
+		name, charset, body, url, encoding string
+	}{
+		{
+			name:     "meta charset",
+			body:     "<meta charset=\"windows-1252\"><a href=\"caf\xe9\"></a>",
+			url:      "https://a/caf%C3%A9",
+			encoding: "windows-1252",
+		},
+		{
+			name:     "meta http-equiv",
+			body:     "<meta http-equiv=\"Content-Type\" content=\"text/html; charset=iso-8859-2\"><a href=\"\xb1\"></a>",
+			url:      "https://a/%C4%85",
+			encoding: "iso-8859-2",
+		},
+		{
+			name:     "header",
+			charset:  "shift_jis",
+			body:     "<a href=\"\x93\xfa\x96\x7b\"></a>",
+			url:      "https://a/%E6%97%A5%E6%9C%AC",
+			encoding: "shift_jis",
+		},
+		{
+			name:     "header overrides meta",
+			charset:  "utf-8",
+			body:     "<meta charset=\"windows-1252\"><a href=\"caf\xc3\xa9\"></a>",
+			url:      "https://a/caf%C3%A9",
+			encoding: "utf-8",
+		},
+		{
+			name:     "bom overrides header",
+			charset:  "windows-1252",
+			body:     "\xef\xbb\xbf<a href=\"caf\xc3\xa9\"></a>",
+			url:      "https://a/caf%C3%A9",
+			encoding: "utf-8",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &Parser{}
+			result := p.Parse(context.Background(), "https://a/", test.charset, strings.NewReader(test.body))
+			if len(result.Links) != 1 || result.Links[0].URL != test.url {
+				t.Errorf("unexpected links %#v", result.Links)
+			}
+			if result.Encoding != test.encoding {
+				t.Errorf("expected encoding %s, got %s", test.encoding, result.Encoding)
+			}
+		})
+	}
+}
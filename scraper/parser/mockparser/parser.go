@@ -6,6 +6,8 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
+
+	"github.com/dave/scrapy/scraper/parser"
 )
 
 // Parser is a parser.Interface that returns dummy urls for a given input, and is used in tests
@@ -15,22 +17,32 @@ type Parser struct {
 
 // Dummy responses
 type Dummy struct {
-	Urls []string // List of urls
-	Errs []string // List of parse errors as strings
+	Urls       []string       // List of urls
+	Excluded   []string       // List of urls rejected by the include function
+	Errs       []string       // List of parse errors as strings
+	Suppressed map[string]int // Number of suppressed links by reason
+	NoIndex    bool           // Did the page ask not to be indexed?
 }
 
-// Parse returns the dummy data if Results contains a matching record.
-func (p *Parser) Parse(ctx context.Context, url string, body io.Reader) (urls []string, errs []error) {
+// Parse returns the dummy data if Results contains a matching record. Urls are returned as anchor links.
+func (p *Parser) Parse(ctx context.Context, url, charset string, body io.Reader) parser.Result {
 	b, err := ioutil.ReadAll(body)
 	if err != nil {
-		return nil, []error{err}
+		return parser.Result{Errs: []error{err}}
 	}
-	result, ok := p.Results[string(b)]
+	dummy, ok := p.Results[string(b)]
 	if !ok {
-		return nil, nil
+		return parser.Result{}
+	}
+	result := parser.Result{Suppressed: dummy.Suppressed, NoIndex: dummy.NoIndex}
+	for _, u := range dummy.Urls {
+		result.Links = append(result.Links, parser.Link{URL: u, Kind: parser.KindAnchor})
+	}
+	for _, u := range dummy.Excluded {
+		result.Excluded = append(result.Excluded, parser.Link{URL: u, Kind: parser.KindAnchor})
 	}
-	for _, e := range result.Errs {
-		errs = append(errs, errors.New(e))
+	for _, e := range dummy.Errs {
+		result.Errs = append(result.Errs, errors.New(e))
 	}
-	return result.Urls, errs
+	return result
 }
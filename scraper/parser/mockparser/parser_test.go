@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 	"reflect"
 	"testing"
+
+	"github.com/dave/scrapy/scraper/parser"
 )
 
 // TODO: Quick test for mock parser - perhaps improve if have time.
@@ -18,12 +20,12 @@ func TestParser(t *testing.T) {
 			},
 		},
 	}
-	urls, errs := p.Parse(context.Background(), "", ioutil.NopCloser(bytes.NewBufferString("a")))
-	expected := []string{"b"}
-	if !reflect.DeepEqual(urls, expected) {
-		t.Errorf("expected urls: %#v, found %#v", expected, urls)
+	result := p.Parse(context.Background(), "", "", ioutil.NopCloser(bytes.NewBufferString("a")))
+	expected := []parser.Link{{URL: "b", Kind: parser.KindAnchor}}
+	if !reflect.DeepEqual(result.Links, expected) {
+		t.Errorf("expected links: %#v, found %#v", expected, result.Links)
 	}
-	if len(errs) > 0 {
-		t.Errorf("expected nil errs, found %#v", errs)
+	if len(result.Errs) > 0 {
+		t.Errorf("expected nil errs, found %#v", result.Errs)
 	}
 }
@@ -1,13 +1,80 @@
-// Package parser defines an interface used to parse HTML and extract links
+// Package parser defines an interface used to parse documents and extract links
 package parser
 
 import (
 	"context"
 	"io"
+	"net/url"
+
+	"github.com/dave/scrapy/scraper/canonical"
 )
 
-// Interface parses HTML and returns the urls from anchor href attributes
+// Interface parses a document and returns the links found
 type Interface interface {
-	// Parse parses the document and returns the urls and parse errors
-	Parse(ctx context.Context, url string, body io.Reader) (urls []string, errs []error)
+	// Parse parses the document and returns the links and parse errors. Charset is the charset from the Content-Type
+	// header of the document (empty if it wasn't specified).
+	Parse(ctx context.Context, url, charset string, body io.Reader) Result
+}
+
+// Result is the result of parsing a document
+type Result struct {
+	Links      []Link         // The links found
+	Excluded   []Link         // The links rejected by the include function - e.g. to other hosts (not reported by all parsers)
+	Errs       []error        // Parse errors
+	Suppressed map[string]int // Number of links that were not returned because of nofollow rules, by reason
+	NoIndex    bool           // Did the document ask not to be indexed (e.g. <meta name="robots" content="noindex">)?
+	Encoding   string         // The character encoding that was detected - e.g. "windows-1252" (empty if not detected)
+	Depth      int            // Number of links followed from the start url to the document (set by the scraper, not the parser)
+}
+
+// Reasons that links are suppressed
+const (
+	RelNofollow  = "rel=nofollow"         // The link has rel="nofollow"
+	MetaNofollow = "meta robots nofollow" // The document has <meta name="robots" content="nofollow">
+)
+
+// Link is a reference to a url found in a document
+type Link struct {
+	URL  string // The absolute url
+	Kind Kind   // The kind of reference
+	Text string // The anchor text, with whitespace collapsed (only for anchors)
+}
+
+// Kind is the kind of reference that a link was found in
+type Kind string
+
+// Kinds of reference
+const (
+	KindAnchor  Kind = "a"       // <a href>
+	KindArea    Kind = "area"    // <area href>
+	KindLink    Kind = "link"    // <link href> - e.g. stylesheets, icons
+	KindImage   Kind = "img"     // <img src> and <img srcset>
+	KindScript  Kind = "script"  // <script src>
+	KindIframe  Kind = "iframe"  // <iframe src>
+	KindForm    Kind = "form"    // <form action>
+	KindRefresh Kind = "refresh" // <meta http-equiv="refresh" content="0; url=...">
+	KindCSS     Kind = "css"     // url(...) in style attributes, <style> elements and css files
+	KindSitemap Kind = "sitemap" // <loc> in sitemap and sitemap index files
+	KindFeed    Kind = "feed"    // <link> in RSS and Atom feeds
+	KindText    Kind = "text"    // Urls in plain text documents
+)
+
+// Kinds contains all the kinds of reference found in HTML documents
+var Kinds = []Kind{KindAnchor, KindArea, KindLink, KindImage, KindScript, KindIframe, KindForm, KindRefresh, KindCSS}
+
+// Resolve resolves a raw url found in a document against the url of the document, and converts it to canonical form
+// (if rules is nil, canonical.Default is used). Returns nil if the include function is not nil and returns false.
+func Resolve(page *url.URL, raw string, rules *canonical.Rules, include func(*url.URL) bool) (*url.URL, error) {
+	u, err := canonical.Resolve(page, raw)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = canonical.Default
+	}
+	u = rules.URL(u)
+	if include != nil && !include(u) {
+		return nil, nil
+	}
+	return u, nil
 }
@@ -0,0 +1,52 @@
+// Package textparser defines a parser.Interface that returns the absolute http and https urls found in plain text
+package textparser
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/dave/scrapy/scraper/canonical"
+	"github.com/dave/scrapy/scraper/parser"
+)
+
+// Parser is a parser.Interface that returns the absolute http and https urls found in plain text
+type Parser struct {
+	Include   func(*url.URL) bool // Include filters the urls - return false to exclude a url
+	Canonical *canonical.Rules    // Rules used to convert urls to canonical form (if nil, canonical.Default is used)
+}
+
+// textURL matches absolute http and https urls
+var textURL = regexp.MustCompile(`https?://[^\s<>"'()\[\]{}]+`)
+
+// Parse parses the text and returns the links and parse errors
+func (p *Parser) Parse(ctx context.Context, urlPage, charset string, body io.Reader) (result parser.Result) {
+	page, err := url.Parse(urlPage)
+	if err != nil {
+		return parser.Result{Errs: []error{err}}
+	}
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return parser.Result{Errs: []error{err}}
+	}
+
+	for _, raw := range textURL.FindAllString(string(b), -1) {
+		// Punctuation at the end is usually part of the sentence, not the url
+		raw = strings.TrimRight(raw, ".,;:!?")
+
+		u, err := parser.Resolve(page, raw, p.Canonical, p.Include)
+		if err != nil {
+			result.Errs = append(result.Errs, err)
+			continue
+		}
+		if u == nil {
+			continue
+		}
+		result.Links = append(result.Links, parser.Link{URL: u.String(), Kind: parser.KindText})
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package textparser
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		inc      func(*url.URL) bool
+		expected []string
+	}{
+		{
+			name:     "simple",
+			body:     "See https://a/b and http://c/d.",
+			expected: []string{"https://a/b", "http://c/d"},
+		},
+		{
+			name:     "brackets and punctuation",
+			body:     "Links (https://a/b), <https://a/c>; \"https://a/d\"!",
+			expected: []string{"https://a/b", "https://a/c", "https://a/d"},
+		},
+		{
+			name:     "include function",
+			body:     "https://a/b https://c/d",
+			inc:      func(u *url.URL) bool { return u.Host == "c" },
+			expected: []string{"https://c/d"},
+		},
+		{
+			name: "relative urls are ignored",
+			body: "/a/b ../c",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &Parser{Include: test.inc}
+			result := p.Parse(context.Background(), "https://a/", "", strings.NewReader(test.body))
+			var urls []string
+			for _, l := range result.Links {
+				urls = append(urls, l.URL)
+			}
+			if !reflect.DeepEqual(urls, test.expected) {
+				t.Errorf("unexpected urls %#v", urls)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Package xmlparser defines a parser.Interface that parses sitemaps and RSS / Atom feeds and returns the urls they
+// list
+package xmlparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/dave/scrapy/scraper/canonical"
+	"github.com/dave/scrapy/scraper/parser"
+	"github.com/dave/scrapy/scraper/sitemap"
+	"golang.org/x/net/html/charset"
+)
+
+// Parser is a parser.Interface that parses sitemaps and RSS / Atom feeds and returns the urls they list. Sitemaps are
+// parsed by the sitemap package, so they are handled the same as the sitemaps fetched by the scraper. Other xml
+// documents return no links.
+type Parser struct {
+	Include   func(*url.URL) bool // Include filters the urls - return false to exclude a url
+	Canonical *canonical.Rules    // Rules used to convert urls to canonical form (if nil, canonical.Default is used)
+}
+
+// feeds are the root elements of feeds
+var feeds = map[string]bool{
+	"rss":  true, // RSS 2.0
+	"RDF":  true, // RSS 1.0
+	"feed": true, // Atom
+}
+
+// Parse parses the document and returns the links and parse errors
+func (p *Parser) Parse(ctx context.Context, urlPage, charset string, body io.Reader) (result parser.Result) {
+	page, err := url.Parse(urlPage)
+	if err != nil {
+		return parser.Result{Errs: []error{err}}
+	}
+
+	// The document is read so it can be parsed as a feed if it isn't a sitemap
+	b, err := ioutil.ReadAll(io.LimitReader(body, sitemap.MaxSize))
+	if err != nil {
+		return parser.Result{Errs: []error{err}}
+	}
+
+	data, err := sitemap.Parse(bytes.NewReader(b))
+	if _, ok := err.(*sitemap.NotSitemapError); ok {
+		return p.feed(ctx, page, b)
+	}
+	if err != nil && err != io.EOF {
+		// An empty document isn't an error
+		result.Errs = append(result.Errs, err)
+	}
+	if data != nil {
+		for _, raw := range append(data.URLs, data.Sitemaps...) {
+			p.add(&result, page, raw, parser.KindSitemap)
+		}
+	}
+	return result
+}
+
+// add resolves a url and adds it to the result, unless it's rejected by the include function
+func (p *Parser) add(result *parser.Result, page *url.URL, raw string, kind parser.Kind) {
+	u, err := parser.Resolve(page, raw, p.Canonical, p.Include)
+	if err != nil {
+		result.Errs = append(result.Errs, err)
+		return
+	}
+	if u == nil {
+		return
+	}
+	result.Links = append(result.Links, parser.Link{URL: u.String(), Kind: kind})
+}
+
+// feed parses an RSS or Atom feed
+func (p *Parser) feed(ctx context.Context, page *url.URL, b []byte) (result parser.Result) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	d.Strict = false
+	d.CharsetReader = charset.NewReaderLabel
+
+	var stack []string // The names of the open elements
+	for {
+		select {
+		case <-ctx.Done():
+			return parser.Result{Errs: []error{ctx.Err()}}
+		default:
+			// great!
+		}
+
+		tok, err := d.Token()
+		if err == io.EOF {
+			return result
+		}
+		if err != nil {
+			result.Errs = append(result.Errs, err)
+			return result
+		}
+
+		var raw string
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && !feeds[tok.Name.Local] {
+				// Other xml documents have no links
+				return result
+			}
+			stack = append(stack, tok.Name.Local)
+
+			// Atom links are in the href attribute
+			if tok.Name.Local == "link" {
+				for _, a := range tok.Attr {
+					if a.Name.Local == "href" {
+						raw = a.Value
+					}
+				}
+			}
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			// RSS links are in <link> elements
+			if len(stack) > 0 && stack[len(stack)-1] == "link" {
+				raw = strings.TrimSpace(string(tok))
+			}
+		}
+		if raw != "" {
+			p.add(&result, page, raw, parser.KindFeed)
+		}
+	}
+}
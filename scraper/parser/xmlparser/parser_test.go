@@ -0,0 +1,111 @@
+package xmlparser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dave/scrapy/scraper/parser"
+)
+
+func TestParser(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		links []parser.Link
+		errs  int
+	}{
+		{
+			name: "sitemap",
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+				<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+					<url><loc> https://a/b </loc><lastmod>2018-01-01</lastmod></url>
+					<url><loc>https://a/c/</loc></url>
+				</urlset>`,
+			links: []parser.Link{
+				{URL: "https://a/b", Kind: parser.KindSitemap},
+				{URL: "https://a/c", Kind: parser.KindSitemap},
+			},
+		},
+		{
+			name: "sitemap index",
+			body: `<sitemapindex><sitemap><loc>https://a/s.xml</loc></sitemap></sitemapindex>`,
+			links: []parser.Link{
+				{URL: "https://a/s.xml", Kind: parser.KindSitemap},
+			},
+		},
+		{
+			name: "rss",
+			body: `<?xml version="1.0" encoding="ISO-8859-1"?>
+				<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel>
+					<title>a</title>
+					<link>https://a/</link>
+					<atom:link href="/feed.xml" rel="self"/>
+					<item><title>b</title><link>/b</link></item>
+				</channel></rss>`,
+			links: []parser.Link{
+				{URL: "https://a/", Kind: parser.KindFeed},
+				{URL: "https://a/feed.xml", Kind: parser.KindFeed},
+				{URL: "https://a/b", Kind: parser.KindFeed},
+			},
+		},
+		{
+			name: "atom",
+			body: `<feed xmlns="http://www.w3.org/2005/Atom">
+					<link href="https://a/"/>
+					<entry><title>b</title><link rel="alternate" href="b"/></entry>
+				</feed>`,
+			links: []parser.Link{
+				{URL: "https://a/", Kind: parser.KindFeed},
+				{URL: "https://a/b", Kind: parser.KindFeed},
+			},
+		},
+		{
+			name: "gzip sitemap",
+			body: gzipped(`<urlset><url><loc>https://a/b</loc></url></urlset>`),
+			links: []parser.Link{
+				{URL: "https://a/b", Kind: parser.KindSitemap},
+			},
+		},
+		{
+			name: "empty",
+			body: ``,
+		},
+		{
+			name: "other xml",
+			body: `<a><loc>https://a/b</loc></a>`,
+		},
+		{
+			name: "parse error",
+			body: `<urlset><url><loc>https://a/b</loc></url><`,
+			links: []parser.Link{
+				{URL: "https://a/b", Kind: parser.KindSitemap},
+			},
+			errs: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &Parser{}
+			result := p.Parse(context.Background(), "https://a/", "", strings.NewReader(test.body))
+			if !reflect.DeepEqual(result.Links, test.links) {
+				t.Errorf("unexpected links %#v", result.Links)
+			}
+			if len(result.Errs) != test.errs {
+				t.Errorf("unexpected errors %v", result.Errs)
+			}
+		})
+	}
+}
+
+// gzipped returns the gzip compressed string
+func gzipped(s string) string {
+	b := &bytes.Buffer{}
+	w := gzip.NewWriter(b)
+	w.Write([]byte(s))
+	w.Close()
+	return b.String()
+}
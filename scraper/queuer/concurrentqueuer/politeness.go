@@ -0,0 +1,101 @@
+package concurrentqueuer
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dave/scrapy/scraper/queuer"
+)
+
+// hosts tracks the politeness state for each host
+type hosts struct {
+	sync.Mutex
+	m map[string]*host
+}
+
+// host is the politeness state for a single host
+type host struct {
+	active  int           // number of items admitted and not yet finished
+	next    time.Time     // earliest time the next item may start
+	waiting []queuer.Item // items waiting for a free slot
+}
+
+// admit applies the politeness rules to an item from the queue. If the item can start immediately it returns true.
+// Otherwise the item is held until the host has a free slot, and is sent on the ready channel later.
+func (q *Queuer) admit(item queuer.Item) bool {
+	if q.HostWorkers == 0 && q.HostDelay == 0 {
+		return true
+	}
+
+	q.hosts.Lock()
+	defer q.hosts.Unlock()
+
+	name := hostname(item.URL)
+	h, ok := q.hosts.m[name]
+	if !ok {
+		h = &host{}
+		q.hosts.m[name] = h
+	}
+
+	// Wait for a free slot if the host is already busy
+	if q.HostWorkers > 0 && h.active >= q.HostWorkers {
+		h.waiting = append(h.waiting, item)
+		return false
+	}
+
+	return q.reserve(h, item)
+}
+
+// reserve takes a slot for the item. If the item must wait for the host delay it is sent on the ready channel when the
+// delay expires and false is returned. Must be called with the hosts lock held.
+func (q *Queuer) reserve(h *host, item queuer.Item) bool {
+	h.active++
+
+	now := time.Now()
+	wait := h.next.Sub(now)
+	if wait <= 0 {
+		h.next = now.Add(q.HostDelay)
+		return true
+	}
+	h.next = h.next.Add(q.HostDelay)
+
+	time.AfterFunc(wait, func() { q.ready <- item })
+	return false
+}
+
+// release frees the slot held by the item, and admits the next item waiting for the host
+func (q *Queuer) release(item queuer.Item) {
+	if q.HostWorkers == 0 && q.HostDelay == 0 {
+		return
+	}
+
+	q.hosts.Lock()
+	defer q.hosts.Unlock()
+
+	name := hostname(item.URL)
+	h := q.hosts.m[name]
+	h.active--
+
+	if len(h.waiting) > 0 {
+		next := h.waiting[0]
+		h.waiting = h.waiting[1:]
+		if q.reserve(h, next) {
+			go func() { q.ready <- next }()
+		}
+	}
+
+	// Forget about hosts that have no more work, so the map doesn't grow forever
+	if h.active == 0 && len(h.waiting) == 0 && !h.next.After(time.Now()) {
+		delete(q.hosts.m, name)
+	}
+}
+
+// hostname returns the host of the url, or an empty string if it's not a valid url
+func hostname(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -3,62 +3,148 @@ package concurrentqueuer
 
 import (
 	"sync"
+	"time"
 
+	"github.com/dave/scrapy/scraper/canonical"
 	"github.com/dave/scrapy/scraper/queuer"
 )
 
 // Queuer is a queuer.Interface that runs several workers concurrently on a queue.
 type Queuer struct {
-	Length                int            // Max queue length
-	Workers               int            // Number of concurrent workers
-	seen                  sync.Map       // Tracks the items that have been pushed in the past
-	queue                 chan string    // The queue of items waiting to process
-	queueWait, workerWait sync.WaitGroup // Waitgroup tracking queue and workers
-	once                  sync.Once      // For initialisation
+	Length                int              // Max queue length
+	Workers               int              // Number of concurrent workers
+	HostWorkers           int              // Max number of concurrent workers for each host (zero for no limit)
+	HostDelay             time.Duration    // Min delay between starting items for the same host
+	Canonical             *canonical.Rules // Rules used to convert urls to canonical form before checking for duplicates (if nil, urls are compared as they are)
+	seen                  sync.Map         // Tracks the urls that have been pushed in the past
+	queue                 chan queuer.Item // The queue of items waiting to process
+	ready                 chan queuer.Item // Items that were delayed by the politeness rules and are now ready to process
+	queueWait, workerWait sync.WaitGroup   // Waitgroup tracking queue and workers
+	once                  sync.Once        // For initialisation
+	hosts                 hosts            // Politeness state for each host
+	backlog               []queuer.Item    // Items pushed with PushWait before Start that didn't fit in the queue
+	started               bool             // Has Start been called?
+	m                     sync.Mutex       // Protects backlog and started
 }
 
 // Start starts processing the queue.
-func (q *Queuer) Start(action func(string)) {
+func (q *Queuer) Start(action func(queuer.Item)) {
 
 	q.ensureInitialised()
 
+	// Items that didn't fit in the queue before Start are queued as space frees up
+	q.m.Lock()
+	q.started = true
+	backlog := q.backlog
+	q.backlog = nil
+	q.m.Unlock()
+	if len(backlog) > 0 {
+		go q.feed(backlog)
+	}
+
 	for i := 0; i < q.Workers; i++ {
-		go func() {
 
-			// Use a waitgroup to ensure we don't exit before the workers have finished exiting.
-			q.workerWait.Add(1)
+		// Use a waitgroup to ensure we don't exit before the workers have finished exiting.
+		q.workerWait.Add(1)
+
+		go func() {
 			defer q.workerWait.Done()
 
-			// Read from the queue channel and perform the action on each item
-			for item := range q.queue {
-				action(item)
-				q.queueWait.Done()
+			// Read from the queue channels and perform the action on each item
+			for {
+				select {
+				case item, ok := <-q.queue:
+					if !ok {
+						return
+					}
+					// Items from the queue must be admitted by the politeness rules
+					if !q.admit(item) {
+						continue
+					}
+					q.run(item, action)
+				case item := <-q.ready:
+					// Items from the ready channel have already been admitted
+					q.run(item, action)
+				}
 			}
 		}()
 	}
 }
 
+// run performs the action on an item that has been admitted by the politeness rules
+func (q *Queuer) run(item queuer.Item, action func(queuer.Item)) {
+	action(item)
+	q.release(item)
+	q.queueWait.Done()
+}
+
 // Push attempts to add an item to the queue. On failure, returns queuer.ErrDuplicate or queuer.ErrFull.
-func (q *Queuer) Push(item string) error {
+func (q *Queuer) Push(item queuer.Item) error {
 
 	q.ensureInitialised()
 
-	if _, loaded := q.seen.LoadOrStore(item, true); loaded {
+	if _, loaded := q.seen.LoadOrStore(q.Canonical.String(item.URL), true); loaded {
 		return queuer.ErrDuplicate
 	}
 
+	// Add to the waitgroup before sending, so a worker can't finish the item first
+	q.queueWait.Add(1)
+
 	select {
 	case q.queue <- item:
 		// Url was added to the queue
-		q.queueWait.Add(1)
 		return nil
 	default:
 		// queue was full - don't want to wait here...
+		q.queueWait.Done()
 		return queuer.ErrFull
 	}
 
 }
 
+// PushWait adds an item to the queue, waiting while the queue is full. Before Start it doesn't wait: items that don't
+// fit are queued as space frees up after Start. On failure, returns queuer.ErrDuplicate. It must not be called by an
+// action, which could wait for itself.
+func (q *Queuer) PushWait(item queuer.Item) error {
+
+	q.ensureInitialised()
+
+	if _, loaded := q.seen.LoadOrStore(q.Canonical.String(item.URL), true); loaded {
+		return queuer.ErrDuplicate
+	}
+
+	q.queueWait.Add(1)
+
+	q.m.Lock()
+	if !q.started {
+		defer q.m.Unlock()
+		// Nothing is taken from the queue before Start, so once it is full the items stay in order
+		select {
+		case q.queue <- item:
+		default:
+			q.backlog = append(q.backlog, item)
+		}
+		return nil
+	}
+	q.m.Unlock()
+
+	q.queue <- item
+	return nil
+}
+
+// feed sends the items that were pushed before Start to the queue, waiting for space
+func (q *Queuer) feed(items []queuer.Item) {
+	for _, item := range items {
+		q.queue <- item
+	}
+}
+
+// Mark adds the url to the seen set without queueing it. Returns false if it had already been seen.
+func (q *Queuer) Mark(url string) bool {
+	_, loaded := q.seen.LoadOrStore(q.Canonical.String(url), true)
+	return !loaded
+}
+
 // Wait waits for all items to be processed before returning.
 func (q *Queuer) Wait() {
 	q.queueWait.Wait()  // wait for the queue to finish
@@ -69,6 +155,8 @@ func (q *Queuer) Wait() {
 // initialises the queue
 func (q *Queuer) ensureInitialised() {
 	q.once.Do(func() {
-		q.queue = make(chan string, q.Length)
+		q.queue = make(chan queuer.Item, q.Length)
+		q.ready = make(chan queuer.Item)
+		q.hosts.m = map[string]*host{}
 	})
 }
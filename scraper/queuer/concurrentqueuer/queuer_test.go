@@ -1,9 +1,12 @@
 package concurrentqueuer
 
 import (
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/dave/scrapy/scraper/canonical"
 	"github.com/dave/scrapy/scraper/queuer"
 )
 
@@ -18,8 +21,8 @@ func TestQueuer_concurrent(t *testing.T) {
 	cSignal := make(chan struct{})
 	cStarted := make(chan struct{})
 
-	q.Start(func(s string) {
-		switch s {
+	q.Start(func(item queuer.Item) {
+		switch item.URL {
 		case "a":
 			close(aStarted)
 			<-aSignal
@@ -33,11 +36,11 @@ func TestQueuer_concurrent(t *testing.T) {
 	})
 
 	// Push two items onto the queue
-	if err := q.Push("a"); err != nil {
+	if err := q.Push(queuer.Item{URL: "a"}); err != nil {
 		t.Errorf("a should succeed, this failed with %v", err)
 	}
 
-	if err := q.Push("b"); err != nil {
+	if err := q.Push(queuer.Item{URL: "b"}); err != nil {
 		t.Errorf("b should succeed, this failed with %v", err)
 	}
 
@@ -50,7 +53,7 @@ func TestQueuer_concurrent(t *testing.T) {
 	}
 
 	// A third action will be queued, but should not start processing until one of the previous actions finishes.
-	if err := q.Push("c"); err != nil {
+	if err := q.Push(queuer.Item{URL: "c"}); err != nil {
 		t.Errorf("c should succeed, this failed with %v", err)
 	}
 	if !timeout(cStarted) {
@@ -82,8 +85,8 @@ func TestQueuer_queue(t *testing.T) {
 	bSignal := make(chan struct{})
 	bStarted := make(chan struct{})
 
-	q.Start(func(s string) {
-		switch s {
+	q.Start(func(item queuer.Item) {
+		switch item.URL {
 		case "a":
 			close(aStarted)
 			<-aSignal
@@ -93,7 +96,7 @@ func TestQueuer_queue(t *testing.T) {
 		}
 	})
 
-	if err := q.Push("a"); err != nil {
+	if err := q.Push(queuer.Item{URL: "a"}); err != nil {
 		t.Errorf("a should succeed, this failed with %v", err)
 	}
 
@@ -103,12 +106,12 @@ func TestQueuer_queue(t *testing.T) {
 	}
 
 	// We push another item. The queue is max length 1, so this will fill the queue
-	if err := q.Push("b"); err != nil {
+	if err := q.Push(queuer.Item{URL: "b"}); err != nil {
 		t.Errorf("b should succeed, this failed with %v", err)
 	}
 
 	// Pushing another item should fail with a full queue
-	if err := q.Push("c"); err != queuer.ErrFull {
+	if err := q.Push(queuer.Item{URL: "c"}); err != queuer.ErrFull {
 		t.Errorf("c should fail with ErrFull, this failed with %v", err)
 	}
 
@@ -121,13 +124,13 @@ func TestQueuer_queue(t *testing.T) {
 	}
 
 	// Now if we re-push any of the three items we should get ErrDuplicate
-	if err := q.Push("a"); err != queuer.ErrDuplicate {
+	if err := q.Push(queuer.Item{URL: "a"}); err != queuer.ErrDuplicate {
 		t.Errorf("a should now fail with ErrDuplicate, this failed with %v", err)
 	}
-	if err := q.Push("b"); err != queuer.ErrDuplicate {
+	if err := q.Push(queuer.Item{URL: "b"}); err != queuer.ErrDuplicate {
 		t.Errorf("b should now fail with ErrDuplicate, this failed with %v", err)
 	}
-	if err := q.Push("c"); err != queuer.ErrDuplicate {
+	if err := q.Push(queuer.Item{URL: "c"}); err != queuer.ErrDuplicate {
 		t.Errorf("c should now fail with ErrDuplicate, this failed with %v", err)
 	}
 
@@ -138,6 +141,88 @@ func TestQueuer_queue(t *testing.T) {
 
 }
 
+// TestQueuer_hostWorkers tests that HostWorkers limits the concurrent actions for each host, but not across hosts
+func TestQueuer_hostWorkers(t *testing.T) {
+	q := &Queuer{Length: 10, Workers: 3, HostWorkers: 1}
+
+	aSignal := make(chan struct{})
+	aStarted := make(chan struct{})
+	bStarted := make(chan struct{})
+	cStarted := make(chan struct{})
+
+	q.Start(func(item queuer.Item) {
+		switch item.URL {
+		case "http://a/1":
+			close(aStarted)
+			<-aSignal
+		case "http://a/2":
+			close(bStarted)
+		case "http://c/1":
+			close(cStarted)
+		}
+	})
+
+	if err := q.Push(queuer.Item{URL: "http://a/1"}); err != nil {
+		t.Errorf("a should succeed, this failed with %v", err)
+	}
+	if timeout(aStarted) {
+		t.Errorf("timed out waiting for a to start processing")
+	}
+
+	// b has the same host as a, so should wait even though there are free workers
+	if err := q.Push(queuer.Item{URL: "http://a/2"}); err != nil {
+		t.Errorf("b should succeed, this failed with %v", err)
+	}
+
+	// c has a different host, so should start
+	if err := q.Push(queuer.Item{URL: "http://c/1"}); err != nil {
+		t.Errorf("c should succeed, this failed with %v", err)
+	}
+	if timeout(cStarted) {
+		t.Errorf("timed out waiting for c to start processing")
+	}
+	if !timeout(bStarted) {
+		t.Errorf("b should not start processing, but it did")
+	}
+
+	// Finish a so b can start
+	close(aSignal)
+	if timeout(bStarted) {
+		t.Errorf("timed out waiting for b to start processing")
+	}
+
+	q.Wait()
+}
+
+// TestQueuer_hostDelay tests that HostDelay spaces out the actions for each host
+func TestQueuer_hostDelay(t *testing.T) {
+	q := &Queuer{Length: 10, Workers: 3, HostDelay: 50 * time.Millisecond}
+
+	var m sync.Mutex
+	started := map[string]time.Time{}
+
+	q.Start(func(item queuer.Item) {
+		m.Lock()
+		defer m.Unlock()
+		started[item.URL] = time.Now()
+	})
+
+	for _, item := range []string{"http://a/1", "http://a/2", "http://b/1"} {
+		if err := q.Push(queuer.Item{URL: item}); err != nil {
+			t.Errorf("%s should succeed, this failed with %v", item, err)
+		}
+	}
+
+	q.Wait()
+
+	if gap := started["http://a/2"].Sub(started["http://a/1"]); gap < 45*time.Millisecond {
+		t.Errorf("expected a delay between items for the same host, found %v", gap)
+	}
+	if gap := started["http://b/1"].Sub(started["http://a/1"]); gap > 45*time.Millisecond {
+		t.Errorf("expected no delay between items for different hosts, found %v", gap)
+	}
+}
+
 func timeout(c chan struct{}) bool {
 	select {
 	case <-c:
@@ -147,3 +232,46 @@ func timeout(c chan struct{}) bool {
 		return true
 	}
 }
+
+// TestQueuer_canonical tests that urls are converted to canonical form before checking for duplicates
+func TestQueuer_canonical(t *testing.T) {
+	q := &Queuer{Length: 10, Workers: 1, Canonical: &canonical.Rules{LowercaseHost: true, StripTracking: true}}
+	q.Start(func(item queuer.Item) {})
+
+	if err := q.Push(queuer.Item{URL: "http://a/b?c=d"}); err != nil {
+		t.Errorf("first push should succeed, this failed with %v", err)
+	}
+	if err := q.Push(queuer.Item{URL: "http://A/b?c=d&utm_source=e"}); err != queuer.ErrDuplicate {
+		t.Errorf("equivalent url should fail with ErrDuplicate, this failed with %v", err)
+	}
+	if err := q.Push(queuer.Item{URL: "http://a/b?c=e"}); err != nil {
+		t.Errorf("different url should succeed, this failed with %v", err)
+	}
+
+	q.Wait()
+}
+
+// TestQueuer_pushWait tests that items pushed with PushWait before Start aren't dropped when the queue is full
+func TestQueuer_pushWait(t *testing.T) {
+	q := &Queuer{Length: 2, Workers: 1}
+
+	urls := []string{"a", "b", "c", "d", "e"}
+	for _, u := range urls {
+		if err := q.PushWait(queuer.Item{URL: u}); err != nil {
+			t.Errorf("%s should succeed, this failed with %v", u, err)
+		}
+	}
+	if err := q.PushWait(queuer.Item{URL: "e"}); err != queuer.ErrDuplicate {
+		t.Errorf("e should fail with ErrDuplicate, this failed with %v", err)
+	}
+
+	var processed []string
+	q.Start(func(item queuer.Item) {
+		processed = append(processed, item.URL)
+	})
+	q.Wait()
+
+	if !reflect.DeepEqual(processed, urls) {
+		t.Errorf("expected %#v to be processed, found %#v", urls, processed)
+	}
+}
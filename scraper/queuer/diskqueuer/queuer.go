@@ -0,0 +1,253 @@
+// Package diskqueuer defines a queuer.Interface that wraps another queuer and persists the pending and finished items
+// to a file, so a crawl can be stopped and resumed
+package diskqueuer
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/dave/scrapy/scraper/canonical"
+	"github.com/dave/scrapy/scraper/queuer"
+)
+
+// Queuer is a queuer.Interface that wraps another queuer and persists the pending and finished items to a file. Create
+// with Open, and call Close to stop recording - e.g. before cancelling the crawl, so items that are interrupted are
+// still pending in the next run.
+type Queuer struct {
+	Queuer    queuer.Interface // The wrapped queuer
+	Canonical *canonical.Rules // Rules used to convert urls to canonical form before comparing them (if nil, urls are compared as they are)
+	file      *os.File         // The log file (nil after Close)
+	err       error            // The first error writing to the log file
+	done      map[string]bool  // Urls that have finished processing, in this run or a previous run, in canonical form
+	pushed    map[string]bool  // Urls that have been recorded as pushed, in this run or a previous run, in canonical form
+	pending   []queuer.Item    // Items that were pending when the previous run ended
+	resumed   map[string]bool  // Urls of the pending items that haven't been pushed again, in canonical form
+	started   bool             // Has Start been called?
+	m         sync.Mutex       // Protects the fields above
+}
+
+// entry is a line in the log file
+type entry struct {
+	Op    string `json:"op"` // "push" or "done"
+	URL   string `json:"url"`
+	Depth int    `json:"depth,omitempty"`
+	Check bool   `json:"check,omitempty"`
+}
+
+// Open opens the log file at path and wraps q, comparing urls in the canonical form from rules. If resume is true the
+// log from a previous run is loaded, otherwise the file is truncated.
+func Open(path string, q queuer.Interface, rules *canonical.Rules, resume bool) (*Queuer, error) {
+	d := &Queuer{
+		Queuer:    q,
+		Canonical: rules,
+		done:      map[string]bool{},
+		pushed:    map[string]bool{},
+		resumed:   map[string]bool{},
+	}
+
+	if resume {
+		if err := d.load(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	// Write a compacted log containing only the current state, and swap it in place of the old one
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	d.file = f
+	for key := range d.done {
+		d.write(entry{Op: "done", URL: key})
+	}
+	for _, item := range d.pending {
+		d.write(entry{Op: "push", URL: item.URL, Depth: item.Depth, Check: item.Check})
+	}
+	if d.err != nil {
+		f.Close()
+		return nil, d.err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// load reads the log file from a previous run
+func (d *Queuer) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var order []queuer.Item
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var e entry
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				// A partial last line is expected if the process was killed while writing
+				break
+			}
+			return err
+		}
+		key := d.Canonical.String(e.URL)
+		switch e.Op {
+		case "push":
+			if !d.pushed[key] {
+				d.pushed[key] = true
+				order = append(order, queuer.Item{URL: e.URL, Depth: e.Depth, Check: e.Check})
+			}
+		case "done":
+			d.done[key] = true
+			d.pushed[key] = true
+		}
+	}
+
+	// Pending items are those that were pushed but didn't finish, in the order they were pushed
+	for _, item := range order {
+		key := d.Canonical.String(item.URL)
+		if !d.done[key] {
+			d.pending = append(d.pending, item)
+			d.resumed[key] = true
+		}
+	}
+	return nil
+}
+
+// Start starts processing the queue.
+func (d *Queuer) Start(action func(queuer.Item)) {
+	d.m.Lock()
+	d.started = true
+	d.m.Unlock()
+
+	d.Queuer.Start(func(item queuer.Item) {
+		action(item)
+		d.record("done", item)
+	})
+}
+
+// Push attempts to add an item to the queue. On failure, returns queuer.ErrDuplicate or queuer.ErrFull. Items that
+// finished in a previous run return queuer.ErrDuplicate. If the wrapped queuer is a queuer.Blocker, items that were
+// pending in the previous run and are pushed before Start are pushed with PushWait, so they don't fail if the queue is
+// full.
+func (d *Queuer) Push(item queuer.Item) error {
+	return d.push(item, false)
+}
+
+// PushWait adds an item to the queue like Push, but waits for space instead of failing with queuer.ErrFull if the
+// wrapped queuer is a queuer.Blocker. It must not be called by an action.
+func (d *Queuer) PushWait(item queuer.Item) error {
+	return d.push(item, true)
+}
+
+// push adds an item to the wrapped queue and records it, waiting for space if wait is true
+func (d *Queuer) push(item queuer.Item, wait bool) error {
+	key := d.Canonical.String(item.URL)
+
+	d.m.Lock()
+	if d.done[key] {
+		d.m.Unlock()
+		return queuer.ErrDuplicate
+	}
+	if !d.started && d.resumed[key] {
+		wait = true
+	}
+	delete(d.resumed, key)
+	d.m.Unlock()
+
+	var err error
+	if b, ok := d.Queuer.(queuer.Blocker); ok && wait {
+		err = b.PushWait(item)
+	} else {
+		err = d.Queuer.Push(item)
+	}
+	if err != nil {
+		return err
+	}
+	d.record("push", item)
+	return nil
+}
+
+// Mark adds the url to the seen set of the wrapped queuer, if it is a queuer.Marker. Returns false if it had already
+// been seen, or finished in a previous run.
+func (d *Queuer) Mark(url string) bool {
+	d.m.Lock()
+	done := d.done[d.Canonical.String(url)]
+	d.m.Unlock()
+
+	if done {
+		return false
+	}
+	if m, ok := d.Queuer.(queuer.Marker); ok {
+		return m.Mark(url)
+	}
+	return true
+}
+
+// Wait waits for all items to be processed before returning.
+func (d *Queuer) Wait() {
+	d.Queuer.Wait()
+}
+
+// Resume returns the items that were pending when the previous run ended.
+func (d *Queuer) Resume() []queuer.Item {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return d.pending
+}
+
+// Close stops recording and closes the log file. Returns the first error encountered writing to the file.
+func (d *Queuer) Close() error {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if d.file == nil {
+		return d.err
+	}
+	if err := d.file.Close(); err != nil && d.err == nil {
+		d.err = err
+	}
+	d.file = nil
+	return d.err
+}
+
+// record updates the state and writes an entry to the log file, unless Close has been called
+func (d *Queuer) record(op string, item queuer.Item) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if d.file == nil {
+		return
+	}
+	key := d.Canonical.String(item.URL)
+	switch op {
+	case "push":
+		if d.pushed[key] {
+			// Already in the log from a previous run
+			return
+		}
+		d.pushed[key] = true
+	case "done":
+		d.done[key] = true
+	}
+	d.write(entry{Op: op, URL: item.URL, Depth: item.Depth, Check: item.Check})
+}
+
+// write writes an entry to the log file and stores the first error
+func (d *Queuer) write(e entry) {
+	if d.err != nil {
+		return
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		d.err = err
+		return
+	}
+	if _, err := d.file.Write(append(b, '\n')); err != nil {
+		d.err = err
+	}
+}
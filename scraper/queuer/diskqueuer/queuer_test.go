@@ -0,0 +1,132 @@
+package diskqueuer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dave/scrapy/scraper/canonical"
+	"github.com/dave/scrapy/scraper/queuer"
+	"github.com/dave/scrapy/scraper/queuer/concurrentqueuer"
+)
+
+// TestQueuer tests that a crawl that is stopped part way through can be resumed
+func TestQueuer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskqueuer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "frontier")
+
+	// The first run is stopped while b is processing, so b and c are never finished
+	q, err := Open(path, &concurrentqueuer.Queuer{Length: 10, Workers: 1}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []string{"a", "b"} {
+		if err := q.Push(queuer.Item{URL: item}); err != nil {
+			t.Errorf("%s should succeed, this failed with %v", item, err)
+		}
+	}
+	var processed []string
+	q.Start(func(item queuer.Item) {
+		processed = append(processed, item.URL)
+		switch item.URL {
+		case "a":
+			if err := q.Push(queuer.Item{URL: "c", Depth: 1, Check: true}); err != nil {
+				t.Errorf("c should succeed, this failed with %v", err)
+			}
+		case "b":
+			if err := q.Close(); err != nil {
+				t.Errorf("close failed with %v", err)
+			}
+		}
+	})
+	q.Wait()
+
+	if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(processed, expected) {
+		t.Errorf("expected %#v to be processed, found %#v", expected, processed)
+	}
+
+	// The second run resumes from the log
+	q, err = Open(path, &concurrentqueuer.Queuer{Length: 10, Workers: 1}, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	if expected := []queuer.Item{{URL: "b"}, {URL: "c", Depth: 1, Check: true}}; !reflect.DeepEqual(q.Resume(), expected) {
+		t.Errorf("expected %#v to be pending, found %#v", expected, q.Resume())
+	}
+	if err := q.Push(queuer.Item{URL: "a"}); err != queuer.ErrDuplicate {
+		t.Errorf("a should fail with ErrDuplicate, this failed with %v", err)
+	}
+	if err := q.Push(queuer.Item{URL: "b"}); err != nil {
+		t.Errorf("b should succeed, this failed with %v", err)
+	}
+
+	// A third run that doesn't resume starts from scratch
+	q, err = Open(path, &concurrentqueuer.Queuer{Length: 10, Workers: 1}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if len(q.Resume()) > 0 {
+		t.Errorf("expected nothing to be pending, found %#v", q.Resume())
+	}
+}
+
+// TestQueuer_resume tests that pending items that don't fit in the queue are queued as space frees up, and that urls
+// are compared in canonical form
+func TestQueuer_resume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskqueuer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "frontier")
+	rules := &canonical.Rules{TrailingSlash: canonical.StripSlash}
+
+	// The first run is closed before anything is processed, except /done/
+	q, err := Open(path, &concurrentqueuer.Queuer{Length: 10, Workers: 1}, rules, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := []string{"https://a/1", "https://a/2", "https://a/3", "https://a/4", "https://a/done/"}
+	for _, u := range urls {
+		if err := q.Push(queuer.Item{URL: u}); err != nil {
+			t.Errorf("%s should succeed, this failed with %v", u, err)
+		}
+	}
+	q.record("done", queuer.Item{URL: "https://a/done/"})
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second run has a queue of length 1, so the pending items must wait for space
+	q, err = Open(path, &concurrentqueuer.Queuer{Length: 1, Workers: 1}, rules, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if err := q.Push(queuer.Item{URL: "https://a/done"}); err != queuer.ErrDuplicate {
+		t.Errorf("https://a/done should fail with ErrDuplicate, this failed with %v", err)
+	}
+	for _, item := range q.Resume() {
+		if err := q.Push(item); err != nil {
+			t.Errorf("%s should succeed, this failed with %v", item.URL, err)
+		}
+	}
+	var processed []string
+	q.Start(func(item queuer.Item) {
+		processed = append(processed, item.URL)
+	})
+	q.Wait()
+
+	if expected := urls[:4]; !reflect.DeepEqual(processed, expected) {
+		t.Errorf("expected %#v to be processed, found %#v", expected, processed)
+	}
+}
@@ -5,9 +5,32 @@ import "errors"
 
 // Interface is used to queue and execute an action on items
 type Interface interface {
-	Start(action func(string)) // Start starts processing the queue.
-	Push(item string) error    // Push attempts to add an item to the queue. On failure, returns ErrDuplicate or ErrFull.
-	Wait()                     // Wait waits for all items to be processed before returning.
+	Start(action func(Item)) // Start starts processing the queue.
+	Push(item Item) error    // Push attempts to add an item to the queue. On failure, returns ErrDuplicate or ErrFull.
+	Wait()                   // Wait waits for all items to be processed before returning.
+}
+
+// Item is an item in the queue
+type Item struct {
+	URL   string // The url, which identifies the item when detecting duplicates
+	Depth int    // Number of links followed from the start url
+	Check bool   // Only check the status of the url, without parsing it - e.g. for links to other hosts
+}
+
+// Resumer is implemented by queuers that persist their state, so a previous run can be resumed
+type Resumer interface {
+	Resume() []Item // Resume returns the items that were pending when the previous run ended
+}
+
+// Marker is implemented by queuers that can add a url to the seen set without queueing it - e.g. the final url of a
+// redirect
+type Marker interface {
+	Mark(url string) bool // Mark adds the url to the seen set. Returns false if it had already been seen.
+}
+
+// Blocker is implemented by queuers that can wait for space in the queue instead of returning ErrFull
+type Blocker interface {
+	PushWait(item Item) error // PushWait adds an item to the queue, waiting while it is full. On failure, returns ErrDuplicate.
 }
 
 // ErrDuplicate is returned by Push when the URL has been pushed before
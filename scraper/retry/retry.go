@@ -0,0 +1,98 @@
+// Package retry defines a policy that decides when failed requests are retried, with exponential backoff and jitter
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+)
+
+// Policy decides when failed requests are retried, and how long to wait before each retry. A nil *Policy never
+// retries.
+type Policy struct {
+	Attempts   int           // Max number of attempts, including the first
+	Backoff    time.Duration // Delay before the first retry, doubled for each later retry
+	MaxBackoff time.Duration // Max delay before a retry, including delays from Retry-After headers (zero for no limit)
+	Jitter     float64       // Fraction of the delay that is randomised, from 0 to 1 - e.g. 0.5 waits between 50% and 150% of the delay
+	Codes      []int         // Status codes that are retried (if nil, DefaultCodes is used)
+}
+
+// DefaultCodes are the status codes that are retried by default
+var DefaultCodes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// permanent errors are not retried, because retrying will give the same result
+var permanent = map[error]bool{
+	getter.ErrDisallowed:       true,
+	getter.ErrRedirectLoop:     true,
+	getter.ErrTooManyRedirects: true,
+	context.Canceled:           true,
+}
+
+// Retry returns the delay before the next attempt, and false if the result shouldn't be retried. Attempt is the number
+// of attempts so far, starting at 1.
+func (p *Policy) Retry(attempt int, r getter.Result) (delay time.Duration, retry bool) {
+	if p == nil || attempt >= p.Attempts {
+		return 0, false
+	}
+	if r.Err != nil && permanent[r.Err] || r.Err == nil && !p.retryCode(r.Code) {
+		return 0, false
+	}
+
+	// Exponential backoff with jitter
+	delay = p.Backoff << uint(attempt-1)
+	if p.Jitter > 0 {
+		delay = time.Duration(float64(delay) * (1 + p.Jitter*(2*rand.Float64()-1)))
+	}
+
+	// The server may ask us to wait longer
+	if after, ok := retryAfter(r.Header); ok && after > delay {
+		delay = after
+	}
+
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay, true
+}
+
+// Reason returns an error describing why a result failed
+func Reason(r getter.Result) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	return fmt.Errorf("response code %d", r.Code)
+}
+
+// retryCode returns true if the status code should be retried
+func (p *Policy) retryCode(code int) bool {
+	codes := p.Codes
+	if codes == nil {
+		codes = DefaultCodes
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter returns the delay from a Retry-After header, which is either a number of seconds or a http date
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), true
+	}
+	return 0, false
+}
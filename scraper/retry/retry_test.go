@@ -0,0 +1,120 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dave/scrapy/scraper/getter"
+)
+
+func TestPolicy(t *testing.T) {
+	policy := &Policy{Attempts: 3, Backoff: time.Second, MaxBackoff: 10 * time.Second}
+	tests := []struct {
+		name    string
+		policy  *Policy
+		attempt int
+		result  getter.Result
+		delay   time.Duration
+		retry   bool
+	}{
+		{
+			name:    "nil policy",
+			attempt: 1,
+			result:  getter.Result{Code: 503},
+		},
+		{
+			name:    "success",
+			policy:  policy,
+			attempt: 1,
+			result:  getter.Result{Code: 200},
+		},
+		{
+			name:    "not found",
+			policy:  policy,
+			attempt: 1,
+			result:  getter.Result{Code: 404},
+		},
+		{
+			name:    "first retry",
+			policy:  policy,
+			attempt: 1,
+			result:  getter.Result{Code: 503},
+			delay:   time.Second,
+			retry:   true,
+		},
+		{
+			name:    "second retry",
+			policy:  policy,
+			attempt: 2,
+			result:  getter.Result{Err: errors.New("connection reset")},
+			delay:   2 * time.Second,
+			retry:   true,
+		},
+		{
+			name:    "max attempts",
+			policy:  policy,
+			attempt: 3,
+			result:  getter.Result{Code: 503},
+		},
+		{
+			name:    "permanent error",
+			policy:  policy,
+			attempt: 1,
+			result:  getter.Result{Err: getter.ErrDisallowed},
+		},
+		{
+			name:    "cancelled",
+			policy:  policy,
+			attempt: 1,
+			result:  getter.Result{Err: context.Canceled},
+		},
+		{
+			name:    "retry after",
+			policy:  policy,
+			attempt: 1,
+			result:  getter.Result{Code: 429, Header: http.Header{"Retry-After": {"5"}}},
+			delay:   5 * time.Second,
+			retry:   true,
+		},
+		{
+			name:    "retry after is limited",
+			policy:  policy,
+			attempt: 1,
+			result:  getter.Result{Code: 429, Header: http.Header{"Retry-After": {"3600"}}},
+			delay:   10 * time.Second,
+			retry:   true,
+		},
+		{
+			name:    "custom codes",
+			policy:  &Policy{Attempts: 2, Backoff: time.Second, Codes: []int{500}},
+			attempt: 1,
+			result:  getter.Result{Code: 500},
+			delay:   time.Second,
+			retry:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delay, retry := test.policy.Retry(test.attempt, test.result)
+			if retry != test.retry {
+				t.Errorf("expected retry %v, got %v", test.retry, retry)
+			}
+			if delay != test.delay {
+				t.Errorf("expected delay %v, got %v", test.delay, delay)
+			}
+		})
+	}
+}
+
+func TestPolicy_jitter(t *testing.T) {
+	p := &Policy{Attempts: 2, Backoff: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		delay, _ := p.Retry(1, getter.Result{Code: 503})
+		if delay < 500*time.Millisecond || delay > 1500*time.Millisecond {
+			t.Fatalf("delay %v out of range", delay)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Package robots parses robots.txt files and tests urls against their rules
+package robots
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Data is a parsed robots.txt file
+type Data struct {
+	Groups   []*Group // Groups of rules, each applying to one or more user-agents
+	Sitemaps []string // Sitemap urls listed in the file
+}
+
+// Group is a set of rules that applies to one or more user-agents
+type Group struct {
+	Agents     []string      // The user-agent tokens this group applies to (lower case)
+	Rules      []Rule        // Allow and Disallow rules in the order they were found
+	CrawlDelay time.Duration // Minimum delay between requests (zero if not specified)
+}
+
+// Rule is a single Allow or Disallow line
+type Rule struct {
+	Allow bool   // Is this an Allow rule (otherwise Disallow)
+	Path  string // The path pattern, which may contain * wildcards and a $ end anchor
+}
+
+// AllowAll is a robots.txt that allows everything (used when the server has no robots.txt)
+var AllowAll = &Data{}
+
+// DisallowAll is a robots.txt that disallows everything (used when the server errors)
+var DisallowAll = &Data{Groups: []*Group{{Agents: []string{"*"}, Rules: []Rule{{Path: "/"}}}}}
+
+// Parse parses a robots.txt file. Unknown lines are ignored.
+func Parse(r io.Reader) (*Data, error) {
+	d := &Data{}
+	var current *Group
+	var inAgents bool // true while reading consecutive user-agent lines
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+
+		// Strip comments and whitespace
+		if i := strings.Index(line, "#"); i > -1 {
+			line = line[:i]
+		}
+		i := strings.Index(line, ":")
+		if i == -1 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+
+		switch key {
+		case "user-agent":
+			// Consecutive user-agent lines share a group
+			if !inAgents {
+				current = &Group{}
+				d.Groups = append(d.Groups, current)
+			}
+			inAgents = true
+			current.Agents = append(current.Agents, strings.ToLower(value))
+			continue
+		case "allow", "disallow":
+			// An empty Disallow means allow everything, so we can ignore it
+			if current != nil && value != "" {
+				current.Rules = append(current.Rules, Rule{Allow: key == "allow", Path: value})
+			}
+		case "crawl-delay":
+			if current != nil {
+				if f, err := strconv.ParseFloat(value, 64); err == nil && f > 0 {
+					current.CrawlDelay = time.Duration(f * float64(time.Second))
+				}
+			}
+		case "sitemap":
+			// Sitemap lines are not part of any group
+			d.Sitemaps = append(d.Sitemaps, value)
+		}
+		inAgents = false
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Group returns the rules for the user-agent. The group with the longest agent token found in the user-agent is
+// chosen, falling back to the "*" group. Returns nil if no group matches.
+func (d *Data) Group(agent string) *Group {
+	agent = strings.ToLower(agent)
+	var best, wildcard *Group
+	var bestLength int
+	for _, g := range d.Groups {
+		for _, a := range g.Agents {
+			switch {
+			case a == "*":
+				if wildcard == nil {
+					wildcard = g
+				}
+			case a != "" && strings.Contains(agent, a) && len(a) > bestLength:
+				best, bestLength = g, len(a)
+			}
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return wildcard
+}
+
+// Allowed tests the url against the rules for the user-agent.
+func (d *Data) Allowed(agent string, u *url.URL) bool {
+	g := d.Group(agent)
+	if g == nil {
+		return true
+	}
+	return g.Allowed(u)
+}
+
+// Allowed tests the url against the group rules. The longest matching rule wins, and Allow wins a tie.
+func (g *Group) Allowed(u *url.URL) bool {
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	allowed := true
+	longest := -1
+	for _, r := range g.Rules {
+		if !match(r.Path, p) {
+			continue
+		}
+		if len(r.Path) > longest || (len(r.Path) == longest && r.Allow) {
+			allowed, longest = r.Allow, len(r.Path)
+		}
+	}
+	return allowed
+}
+
+// match tests if the path matches the pattern. Patterns match path prefixes, "*" matches any sequence of characters
+// and a trailing "$" anchors the pattern to the end of the path.
+func match(pattern, path string) bool {
+	if pattern == "" {
+		return true
+	}
+	if pattern == "$" {
+		return path == ""
+	}
+	if pattern[0] == '*' {
+		// Try to match the rest of the pattern at every position
+		for i := 0; i <= len(path); i++ {
+			if match(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if path == "" || pattern[0] != path[0] {
+		return false
+	}
+	return match(pattern[1:], path[1:])
+}
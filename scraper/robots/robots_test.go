@@ -0,0 +1,82 @@
+package robots
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testFile = `
+# comment
+User-agent: scrapy
+User-agent: other
+Disallow: /private
+Allow: /private/public
+Crawl-delay: 1.5
+
+User-agent: *
+Disallow: /
+Allow: /$
+Allow: /*.html$
+
+Sitemap: https://a/sitemap.xml
+`
+
+func TestParse(t *testing.T) {
+	d, err := Parse(strings.NewReader(testFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Groups) != 2 {
+		t.Fatalf("expected 2 groups, found %d", len(d.Groups))
+	}
+	if !reflect.DeepEqual(d.Groups[0].Agents, []string{"scrapy", "other"}) {
+		t.Errorf("unexpected agents %#v", d.Groups[0].Agents)
+	}
+	if d.Groups[0].CrawlDelay != 1500*time.Millisecond {
+		t.Errorf("unexpected crawl delay %v", d.Groups[0].CrawlDelay)
+	}
+	if !reflect.DeepEqual(d.Sitemaps, []string{"https://a/sitemap.xml"}) {
+		t.Errorf("unexpected sitemaps %#v", d.Sitemaps)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	d, err := Parse(strings.NewReader(testFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, agent, url string
+		data             *Data
+		expected         bool
+	}{
+		{name: "agent not disallowed", agent: "scrapy/1.0", url: "https://a/b", expected: true},
+		{name: "agent disallowed", agent: "scrapy/1.0", url: "https://a/private/b", expected: false},
+		{name: "longer allow wins", agent: "Scrapy", url: "https://a/private/public/b", expected: true},
+		{name: "wildcard group", agent: "foo", url: "https://a/b", expected: false},
+		{name: "end anchor", agent: "foo", url: "https://a/", expected: true},
+		{name: "end anchor empty path", agent: "foo", url: "https://a", expected: true},
+		{name: "wildcard pattern", agent: "foo", url: "https://a/b/c.html", expected: true},
+		{name: "wildcard pattern with query", agent: "foo", url: "https://a/b/c.html?d", expected: false},
+		{name: "allow all", agent: "foo", url: "https://a/b", data: AllowAll, expected: true},
+		{name: "disallow all", agent: "foo", url: "https://a/", data: DisallowAll, expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := url.Parse(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := d
+			if test.data != nil {
+				data = test.data
+			}
+			if found := data.Allowed(test.agent, u); found != test.expected {
+				t.Errorf("expected %v, found %v", test.expected, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+// Package scope decides which urls are in the scope of a crawl, using composable rules
+package scope
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Scope decides which urls are in scope: a url is in scope if its scheme is allowed, it matches any of the Include
+// rules, and it matches none of the Exclude rules. Use Match as the Include function of a parser.
+type Scope struct {
+	Schemes []string // Allowed schemes (if nil, http and https)
+	Include []Rule   // A url must match one of these rules (if nil, all urls match)
+	Exclude []Rule   // A url must match none of these rules
+}
+
+// Match returns true if the url is in scope
+func (s *Scope) Match(u *url.URL) bool {
+	if u == nil {
+		return false
+	}
+	schemes := s.Schemes
+	if schemes == nil {
+		schemes = []string{"http", "https"}
+	}
+	if !Scheme(schemes...).Match(u) {
+		return false
+	}
+	if s.Include != nil && !Any(s.Include...).Match(u) {
+		return false
+	}
+	return !Any(s.Exclude...).Match(u)
+}
+
+// Rule matches urls
+type Rule interface {
+	Match(u *url.URL) bool // Match returns true if the url matches the rule
+}
+
+// RuleFunc is a function that implements Rule
+type RuleFunc func(u *url.URL) bool
+
+// Match calls the function
+func (f RuleFunc) Match(u *url.URL) bool {
+	return f(u)
+}
+
+// All matches urls that match all of the rules (or all urls if there are no rules)
+func All(rules ...Rule) Rule {
+	return RuleFunc(func(u *url.URL) bool {
+		for _, r := range rules {
+			if !r.Match(u) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Any matches urls that match any of the rules (or no urls if there are no rules)
+func Any(rules ...Rule) Rule {
+	return RuleFunc(func(u *url.URL) bool {
+		for _, r := range rules {
+			if r.Match(u) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Not matches urls that don't match the rule
+func Not(rule Rule) Rule {
+	return RuleFunc(func(u *url.URL) bool {
+		return !rule.Match(u)
+	})
+}
+
+// Host matches urls with one of the hosts, ignoring case. Hosts with a port only match urls with the same port, and
+// hosts without a port match any port - e.g. "example.com" matches "http://EXAMPLE.com:8080/".
+func Host(hosts ...string) Rule {
+	return RuleFunc(func(u *url.URL) bool {
+		for _, h := range hosts {
+			if strings.Contains(h, ":") && strings.EqualFold(u.Host, h) {
+				return true
+			}
+			if strings.EqualFold(u.Hostname(), h) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// HostSuffix matches urls where the host ends with one of the suffixes, ignoring case - e.g. ".example.com". Suffixes
+// only match whole labels, so "example.com" matches "example.com" and "www.example.com" but not "badexample.com".
+func HostSuffix(suffixes ...string) Rule {
+	return RuleFunc(func(u *url.URL) bool {
+		host := strings.ToLower(u.Hostname())
+		for _, s := range suffixes {
+			s = strings.ToLower(s)
+			if !strings.HasPrefix(s, ".") {
+				if host == s {
+					return true
+				}
+				s = "." + s
+			}
+			if strings.HasSuffix(host, s) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// HostGlob matches urls where the host matches one of the glob patterns, ignoring case - e.g. "*.example.com" or
+// "shop-??.example.com". The syntax is the same as path.Match, and wildcards only match within a label, so "*" doesn't
+// match ".". Returns an error if a pattern is malformed.
+func HostGlob(patterns ...string) (Rule, error) {
+	for _, p := range patterns {
+		if _, err := path.Match(labels(p), ""); err != nil {
+			return nil, fmt.Errorf("bad host glob %q: %v", p, err)
+		}
+	}
+	return RuleFunc(func(u *url.URL) bool {
+		host := labels(u.Hostname())
+		for _, p := range patterns {
+			if ok, _ := path.Match(labels(p), host); ok {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// labels converts a host to lower case and separates the labels with "/", so path.Match wildcards don't match "."
+func labels(host string) string {
+	return strings.Replace(strings.ToLower(host), ".", "/", -1)
+}
+
+// Subdomains matches urls where the host is one of the domains or a subdomain of it, ignoring case - e.g.
+// "example.com" matches "example.com", "www.example.com" and "a.b.example.com"
+func Subdomains(domains ...string) Rule {
+	return RuleFunc(func(u *url.URL) bool {
+		host := strings.ToLower(u.Hostname())
+		for _, d := range domains {
+			d = strings.ToLower(d)
+			if host == d || strings.HasSuffix(host, "."+d) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// PathPrefix matches urls where the path starts with one of the prefixes - e.g. "/blog/"
+func PathPrefix(prefixes ...string) Rule {
+	return RuleFunc(func(u *url.URL) bool {
+		p := u.EscapedPath()
+		if p == "" {
+			p = "/"
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(p, prefix) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Regexp matches urls where the whole url matches the regular expression
+func Regexp(re *regexp.Regexp) Rule {
+	return RuleFunc(func(u *url.URL) bool {
+		return re.MatchString(u.String())
+	})
+}
+
+// Scheme matches urls with one of the schemes, ignoring case
+func Scheme(schemes ...string) Rule {
+	return RuleFunc(func(u *url.URL) bool {
+		for _, s := range schemes {
+			if strings.EqualFold(u.Scheme, s) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Query matches urls with any of the query parameters - e.g. "sessionid"
+func Query(names ...string) Rule {
+	return RuleFunc(func(u *url.URL) bool {
+		query := u.Query()
+		for _, n := range names {
+			if _, ok := query[n]; ok {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Config is the configuration file format for a scope (JSON)
+type Config struct {
+	Schemes []string     `json:"schemes,omitempty"` // Allowed schemes (default http and https)
+	Include []RuleConfig `json:"include,omitempty"` // A url must match one of these rules
+	Exclude []RuleConfig `json:"exclude,omitempty"` // A url must match none of these rules
+}
+
+// RuleConfig configures a rule. A url matches if it matches all the fields that are set.
+type RuleConfig struct {
+	Host       string   `json:"host,omitempty"`        // See Host
+	HostSuffix string   `json:"host_suffix,omitempty"` // See HostSuffix
+	HostGlob   string   `json:"host_glob,omitempty"`   // See HostGlob
+	Subdomains string   `json:"subdomains,omitempty"`  // See Subdomains
+	PathPrefix string   `json:"path_prefix,omitempty"` // See PathPrefix
+	Regexp     string   `json:"regexp,omitempty"`      // See Regexp
+	Query      []string `json:"query,omitempty"`       // See Query
+}
+
+// ErrEmptyRule is returned when a rule in a config file has no fields set
+var ErrEmptyRule = errors.New("scope rule has no fields")
+
+// Rule returns the rule
+func (c RuleConfig) Rule() (Rule, error) {
+	var rules []Rule
+	if c.Host != "" {
+		rules = append(rules, Host(c.Host))
+	}
+	if c.HostSuffix != "" {
+		rules = append(rules, HostSuffix(c.HostSuffix))
+	}
+	if c.HostGlob != "" {
+		r, err := HostGlob(c.HostGlob)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	if c.Subdomains != "" {
+		rules = append(rules, Subdomains(c.Subdomains))
+	}
+	if c.PathPrefix != "" {
+		rules = append(rules, PathPrefix(c.PathPrefix))
+	}
+	if c.Regexp != "" {
+		re, err := regexp.Compile(c.Regexp)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, Regexp(re))
+	}
+	if c.Query != nil {
+		rules = append(rules, Query(c.Query...))
+	}
+	if rules == nil {
+		return nil, ErrEmptyRule
+	}
+	return All(rules...), nil
+}
+
+// Scope returns the scope
+func (c Config) Scope() (*Scope, error) {
+	s := &Scope{Schemes: c.Schemes}
+	for _, rc := range c.Include {
+		r, err := rc.Rule()
+		if err != nil {
+			return nil, err
+		}
+		s.Include = append(s.Include, r)
+	}
+	for _, rc := range c.Exclude {
+		r, err := rc.Rule()
+		if err != nil {
+			return nil, err
+		}
+		s.Exclude = append(s.Exclude, r)
+	}
+	return s, nil
+}
+
+// Parse parses a config file
+func Parse(r io.Reader) (Config, error) {
+	var c Config
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// Load reads a config file
+func Load(filename string) (Config, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Config{}, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
@@ -0,0 +1,182 @@
+package scope
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	glob, err := HostGlob("shop-*.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		rule  Rule
+		match []string
+		miss  []string
+	}{
+		{
+			name:  "host",
+			rule:  Host("example.com"),
+			match: []string{"http://example.com/a", "https://EXAMPLE.com:8080/"},
+			miss:  []string{"http://www.example.com/", "http://example.org/"},
+		},
+		{
+			name:  "host with port",
+			rule:  Host("localhost:8080"),
+			match: []string{"http://localhost:8080/"},
+			miss:  []string{"http://localhost/", "http://localhost:9090/"},
+		},
+		{
+			name:  "host suffix",
+			rule:  HostSuffix(".example.com"),
+			match: []string{"http://www.example.com/", "http://a.b.example.com/"},
+			miss:  []string{"http://example.com/", "http://badexample.com/"},
+		},
+		{
+			name:  "host suffix without dot",
+			rule:  HostSuffix("example.com"),
+			match: []string{"http://example.com/", "http://www.EXAMPLE.com/"},
+			miss:  []string{"http://badexample.com/", "http://example.com.evil.org/"},
+		},
+		{
+			name:  "host glob",
+			rule:  glob,
+			match: []string{"http://shop-uk.example.com/", "http://SHOP-us.example.com/"},
+			miss:  []string{"http://shop.example.com/", "http://shop-uk.a.example.com/"},
+		},
+		{
+			name:  "subdomains",
+			rule:  Subdomains("example.com"),
+			match: []string{"http://example.com/", "http://www.example.com/", "http://a.b.example.com/"},
+			miss:  []string{"http://badexample.com/", "http://example.com.evil.org/"},
+		},
+		{
+			name:  "path prefix",
+			rule:  PathPrefix("/blog/", "/news"),
+			match: []string{"http://a/blog/", "http://a/blog/post", "http://a/news/1"},
+			miss:  []string{"http://a/blog", "http://a/", "http://a"},
+		},
+		{
+			name:  "regexp",
+			rule:  Regexp(regexp.MustCompile(`\.pdf$`)),
+			match: []string{"http://a/b.pdf"},
+			miss:  []string{"http://a/b.pdf?download"},
+		},
+		{
+			name:  "scheme",
+			rule:  Scheme("https"),
+			match: []string{"https://a/", "HTTPS://a/"},
+			miss:  []string{"http://a/", "ftp://a/"},
+		},
+		{
+			name:  "query",
+			rule:  Query("sessionid", "sort"),
+			match: []string{"http://a/?sessionid=1", "http://a/?x=1&sort"},
+			miss:  []string{"http://a/", "http://a/?session=1"},
+		},
+		{
+			name:  "all",
+			rule:  All(Host("a"), PathPrefix("/b")),
+			match: []string{"http://a/b"},
+			miss:  []string{"http://a/c", "http://c/b"},
+		},
+		{
+			name:  "any",
+			rule:  Any(Host("a"), PathPrefix("/b")),
+			match: []string{"http://a/c", "http://c/b"},
+			miss:  []string{"http://c/c"},
+		},
+		{
+			name:  "not",
+			rule:  Not(Host("a")),
+			match: []string{"http://b/"},
+			miss:  []string{"http://a/"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, raw := range test.match {
+				if !test.rule.Match(parse(t, raw)) {
+					t.Errorf("%s should match", raw)
+				}
+			}
+			for _, raw := range test.miss {
+				if test.rule.Match(parse(t, raw)) {
+					t.Errorf("%s should not match", raw)
+				}
+			}
+		})
+	}
+}
+
+func TestScope(t *testing.T) {
+	config, err := Parse(strings.NewReader(`{
+		"include": [
+			{"subdomains": "example.com"},
+			{"host": "docs.example.org", "path_prefix": "/api/"}
+		],
+		"exclude": [
+			{"regexp": "/private/"},
+			{"query": ["sessionid"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := config.Scope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		"https://example.com/":                 true,
+		"https://www.example.com/a":            true,
+		"https://docs.example.org/api/v1":      true,
+		"https://docs.example.org/guide":       false,
+		"https://example.com/private/a":        false,
+		"https://example.com/a?sessionid=1":    false,
+		"mailto:a@example.com":                 false,
+		"ftp://example.com/":                   false,
+		"https://example.org/":                 false,
+		"https://www.example.com/a?session=ok": true,
+	}
+	for raw, expected := range tests {
+		if s.Match(parse(t, raw)) != expected {
+			t.Errorf("%s: expected %v", raw, expected)
+		}
+	}
+	if s.Match(nil) {
+		t.Error("nil url should not match")
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	tests := map[string]string{
+		"empty rule":    `{"include": [{}]}`,
+		"bad regexp":    `{"exclude": [{"regexp": "("}]}`,
+		"bad glob":      `{"include": [{"host_glob": "["}]}`,
+		"unknown field": `{"include": [{"hots": "a"}]}`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			config, err := Parse(strings.NewReader(body))
+			if err == nil {
+				_, err = config.Scope()
+			}
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func parse(t *testing.T, raw string) *url.URL {
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
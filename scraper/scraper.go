@@ -3,64 +3,112 @@ package scraper
 
 import (
 	"context"
-	"errors"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/dave/scrapy/scraper/canonical"
 	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/graph"
+	"github.com/dave/scrapy/scraper/linkcheck"
 	"github.com/dave/scrapy/scraper/logger"
 	"github.com/dave/scrapy/scraper/parser"
 	"github.com/dave/scrapy/scraper/queuer"
+	"github.com/dave/scrapy/scraper/retry"
 )
 
 // State implements a web scraper
 type State struct {
-	Timeout time.Duration    // Timeout for each individual item
-	Getter  getter.Interface // Getter gets the page
-	Parser  parser.Interface // Parser parses links
-	Queuer  queuer.Interface // Queuer queues new items and starts queued items
-	Logger  logger.Interface // Logger logs the results
+	Timeout  time.Duration               // Timeout for each individual item
+	MaxDepth *int                        // Links are not followed from pages at this depth, so zero only gets the seed urls (nil for no limit)
+	Getter   getter.Interface            // Getter gets the page
+	Parser   parser.Interface            // Parser parses links from HTML and XHTML documents
+	Parsers  map[string]parser.Interface // Parsers parse other kinds of document, by media type (documents without a parser have no links)
+	Queuer   queuer.Interface            // Queuer queues new items and starts queued items
+	Logger   logger.Interface            // Logger logs the results
+	Retry    *retry.Policy               // Retry decides when failed requests are retried (if nil, requests are not retried)
+	Graph    *graph.Graph                // Graph records the pages and the links between them (optional)
+	Checker  *linkcheck.Checker          // Checker records the links to each url and its status, to report broken links (optional)
+
+	// CheckExternal queues the external links rejected by the parser's include function, so their status is checked
+	// with an anonymous HEAD request without parsing them. External decides which links are external - e.g. links to
+	// hosts that aren't crawled (if nil, links to other hosts than the page). Other rejected links were excluded on
+	// purpose, so they aren't checked.
+	CheckExternal bool
+	External      func(*url.URL) bool
+
+	// Sitemaps enables fetching the sitemaps listed in robots.txt and /sitemap.xml. The urls they list are queued
+	// with the start url, and the logger is sent the sitemap urls that were never linked and the crawled pages that
+	// were missing from the sitemap.
+	Sitemaps  bool
+	Canonical *canonical.Rules    // Rules used to convert sitemap urls, links and crawled pages to canonical form, so they can be compared
+	Include   func(*url.URL) bool // Only sitemap urls that are accepted are queued, and sitemap files on other hosts are only fetched if accepted (if nil, all are)
+
+	inSitemap map[string]bool // Urls listed in the sitemaps
+	linked    map[string]bool // Urls linked from crawled pages
+	crawled   map[string]bool // Pages that were crawled successfully
+	m         sync.Mutex      // Protects the maps above
 }
 
-// Start starts the scraping with a base url. Cancel the context to end early.
-func (s *State) Start(ctx context.Context, url string) {
+// Start starts the scraping with one or more seed urls. Cancel the context to end early.
+func (s *State) Start(ctx context.Context, urls ...string) {
 
 	// Initialise the logger
 	s.Logger.Init()
 
-	// Push the initial url onto the queue
-	if err := s.Queuer.Push(url); err != nil {
-		panic("error in initial queue push")
+	// Push the seed urls onto the queue, even if there are more than the queue length. If the queuer is resuming, a
+	// seed url may have finished in a previous run.
+	for _, url := range urls {
+		s.pushWait(queuer.Item{URL: url})
+	}
+
+	// Queue the urls listed in the sitemaps of each site
+	if s.Sitemaps {
+		s.inSitemap = map[string]bool{}
+		s.linked = map[string]bool{}
+		s.crawled = map[string]bool{}
+		for _, url := range urls {
+			s.linked[s.Canonical.String(url)] = true // The seed urls don't need to be linked
+		}
+		for _, url := range siteRoots(urls) {
+			for _, u := range s.sitemapURLs(ctx, url) {
+				// Urls that couldn't be queued weren't crawled, so they aren't reported as orphans
+				if s.pushWait(queuer.Item{URL: u}) != queuer.ErrFull {
+					s.inSitemap[u] = true
+				}
+			}
+		}
+	}
+
+	// Queue the items that were pending when the previous run ended. Pending seed urls have already been queued.
+	if r, ok := s.Queuer.(queuer.Resumer); ok {
+		for _, item := range r.Resume() {
+			s.pushWait(item)
+		}
 	}
-	// Log that the url was queued correctly
-	s.Logger.Queued(url)
 
 	// Start the queue processing
-	s.Queuer.Start(func(url string) {
+	s.Queuer.Start(func(item queuer.Item) {
 
-		ctx, cancel := context.WithTimeout(ctx, s.Timeout)
-		defer cancel()
+		url := item.URL
 
 		// Log that the url has started processing
-		s.Logger.Starting(url)
+		s.Logger.Starting(url, item.Depth)
 
 		start := time.Now()
 
-		// Start the getter
-		c := s.Getter.Get(ctx, url)
+		// Links that are only checked aren't parsed, so only the headers are needed
+		options := getter.Options{Head: item.Check, Anonymous: item.Check}
 
-		// Wait for the getter to start streaming the contents, but respect context cancellation
-		var r getter.Result
-		select {
-		case <-ctx.Done():
-			s.Logger.Error(url, ctx.Err())
-			return
-		case r = <-c:
-			// great!
-		}
+		// Get the page, retrying transient failures. The context of the last attempt is also used for parsing.
+		ctx, r, cancel := s.get(ctx, url, options)
+		defer cancel()
 
 		// Log error
 		if r.Err != nil {
-			s.Logger.Error(url, r.Err)
+			s.logError(url, r.Redirects, r.Err)
+			s.Checker.Result(url, 0, r.Err)
 			return
 		}
 
@@ -69,19 +117,54 @@ func (s *State) Start(ctx context.Context, url string) {
 			defer r.Body.Close()
 		}
 
+		// The url of the content may be different if the request was redirected
+		final := url
+		if r.URL != "" {
+			final = r.URL
+		}
+		stats := logger.Stats{URL: final, Redirects: r.Redirects, Depth: item.Depth, Code: r.Code, Cache: r.Cache}
+		s.Checker.Result(url, r.Code, nil)
+
+		// Links that are only checked are finished
+		if item.Check {
+			stats.Latency = time.Now().Sub(start)
+			s.Logger.Finished(url, stats)
+			return
+		}
+
+		// Add the final url to the seen set. If it had already been seen, it has been (or will be) processed with
+		// that url, so there's no need to continue.
+		if m, ok := s.Queuer.(queuer.Marker); ok && final != url && !m.Mark(final) {
+			stats.Latency = time.Now().Sub(start)
+			s.Logger.Finished(url, stats)
+			s.addToGraph(final, r, nil)
+			return
+		}
+
 		// Don't continue if the code is not 200
 		if r.Code != 200 {
-			s.Logger.Finished(url, r.Code, time.Now().Sub(start), 0, 0)
+			stats.Latency = time.Now().Sub(start)
+			s.Logger.Finished(url, stats)
+			s.addToGraph(final, r, nil)
 			return
 		}
 
-		if !r.HTML {
-			s.Logger.Error(url, errors.New("contents were not HTML"))
+		// Don't parse the body if the getter skipped it - e.g. a media type that isn't parsed
+		if r.Skipped {
+			stats.Skipped = true
+			stats.MediaType = r.MediaType
+			stats.Latency = time.Now().Sub(start)
+			s.Logger.Finished(url, stats)
+			s.addToGraph(final, r, nil)
 			return
 		}
 
-		// Parse the body
-		urls, errs := s.Parser.Parse(ctx, url, r.Body)
+		// Parse the body with the parser for the media type, resolving relative links against the final url
+		var result parser.Result
+		if p := s.parser(r.MediaType); p != nil {
+			result = p.Parse(ctx, final, r.Charset, r.Body)
+		}
+		result.Depth = item.Depth
 
 		// Perhaps the parser ended early because of cancellation? If so, log the error.
 		select {
@@ -93,22 +176,193 @@ func (s *State) Start(ctx context.Context, url string) {
 		}
 
 		// Log the finish event
-		s.Logger.Finished(url, r.Code, time.Now().Sub(start), len(urls), len(errs))
+		stats.Latency = time.Now().Sub(start)
+		stats.Urls = len(result.Links)
+		stats.Errors = len(result.Errs)
+		stats.Suppressed = result.Suppressed
+		stats.NoIndex = result.NoIndex
+		stats.MediaType = r.MediaType
+		stats.Encoding = result.Encoding
+		stats.Truncated = r.Truncated
+		s.Logger.Finished(url, stats)
+		s.addToGraph(final, r, result.Links)
 
-		// Queue all the resulting urls
-		for _, u := range urls {
-			if err := s.Queuer.Push(u); err != nil {
-				s.Logger.Error(u, err)
-				continue
+		// Record the links to report broken links
+		for _, l := range result.Links {
+			s.Checker.Link(final, l)
+		}
+		external := s.external(final, result.Excluded)
+		for _, l := range external {
+			s.Checker.Link(final, l)
+		}
+
+		// Record the links and the crawled page, to compare with the sitemap
+		if s.Sitemaps {
+			s.m.Lock()
+			for _, l := range result.Links {
+				s.linked[s.Canonical.String(l.URL)] = true
+			}
+			if htmlTypes[r.MediaType] && !result.NoIndex {
+				s.crawled[s.Canonical.String(final)] = true
 			}
-			// Log if the push succeeded
-			s.Logger.Queued(u)
+			s.m.Unlock()
+		}
+
+		// Don't follow links from pages at the max depth
+		if s.MaxDepth != nil && result.Depth >= *s.MaxDepth {
+			return
+		}
+
+		// Queue all the resulting urls
+		for _, l := range result.Links {
+			s.push(queuer.Item{URL: l.URL, Depth: result.Depth + 1})
+		}
+
+		// Queue the external links to check their status
+		for _, l := range external {
+			s.push(queuer.Item{URL: l.URL, Depth: result.Depth + 1, Check: true})
 		}
 	})
 
 	// Wait for the queue to finish processing
 	s.Queuer.Wait()
 
+	// Report the differences between the sitemap and the crawl
+	if s.Sitemaps {
+		s.Logger.Sitemap(s.sitemapReport())
+	}
+
 	// Signal to the logger that we're exiting
 	s.Logger.Exit()
 }
+
+// external returns the excluded links that are external http(s) links, if CheckExternal is set
+func (s *State) external(page string, excluded []parser.Link) []parser.Link {
+	if !s.CheckExternal {
+		return nil
+	}
+	pu, err := url.Parse(page)
+	if err != nil {
+		return nil
+	}
+	var links []parser.Link
+	for _, l := range excluded {
+		u, err := url.Parse(l.URL)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+			continue
+		}
+		external := !strings.EqualFold(u.Host, pu.Host)
+		if s.External != nil {
+			external = s.External(u)
+		}
+		if external {
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// logError logs an error, with the redirects that were followed before it if the logger reports them
+func (s *State) logError(url string, redirects []getter.Redirect, err error) {
+	if rl, ok := s.Logger.(logger.Redirecter); ok && len(redirects) > 0 {
+		rl.RedirectError(url, redirects, err)
+		return
+	}
+	s.Logger.Error(url, err)
+}
+
+// addToGraph records the page, the redirects that led to it and its links in the graph (if there is one)
+func (s *State) addToGraph(final string, r getter.Result, links []parser.Link) {
+	if s.Graph == nil {
+		return
+	}
+	for i, hop := range r.Redirects {
+		to := final
+		if i+1 < len(r.Redirects) {
+			to = r.Redirects[i+1].URL
+		}
+		s.Graph.AddPage(hop.URL, hop.Code)
+		s.Graph.AddEdge(graph.Edge{From: hop.URL, To: to, Kind: graph.KindRedirect})
+	}
+	s.Graph.AddPage(final, r.Code)
+	for _, l := range links {
+		s.Graph.AddEdge(graph.Edge{From: final, To: l.URL, Text: l.Text, Kind: l.Kind})
+	}
+}
+
+// htmlTypes are the media types that are parsed by Parser
+var htmlTypes = map[string]bool{"text/html": true, "application/xhtml+xml": true}
+
+// parser returns the parser for a media type, or nil if there isn't one
+func (s *State) parser(mediaType string) parser.Interface {
+	if p, ok := s.Parsers[mediaType]; ok {
+		return p
+	}
+	if htmlTypes[mediaType] {
+		return s.Parser
+	}
+	return nil
+}
+
+// get gets a url, retrying failures according to the retry policy. Each attempt has its own timeout. Returns the
+// context of the last attempt, which should be cancelled after the body has been read.
+func (s *State) get(ctx context.Context, url string, options getter.Options) (context.Context, getter.Result, context.CancelFunc) {
+	for attempt := 1; ; attempt++ {
+		actx, cancel := context.WithTimeout(ctx, s.Timeout)
+
+		// Wait for the getter to start streaming the contents, but respect context cancellation
+		var r getter.Result
+		select {
+		case <-actx.Done():
+			r = getter.Result{Err: actx.Err()}
+		case r = <-s.Getter.Get(actx, url, options):
+			// great!
+		}
+
+		delay, again := s.Retry.Retry(attempt, r)
+		if !again || ctx.Err() != nil {
+			return actx, r, cancel
+		}
+
+		// Discard this attempt and wait before the next one
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		cancel()
+		s.Logger.Retrying(url, attempt, delay, retry.Reason(r))
+
+		select {
+		case <-ctx.Done():
+			return ctx, getter.Result{Err: ctx.Err()}, func() {}
+		case <-time.After(delay):
+			// great!
+		}
+	}
+}
+
+// push adds an item to the queue and logs the result. Returns the error from the queuer.
+func (s *State) push(item queuer.Item) error {
+	if err := s.Queuer.Push(item); err != nil {
+		s.Logger.Error(item.URL, err)
+		return err
+	}
+	// Log if the push succeeded
+	s.Logger.Queued(item.URL)
+	return nil
+}
+
+// pushWait adds an item to the queue like push, but waits for space instead of failing with queuer.ErrFull if the
+// queuer is a queuer.Blocker. It is used for the urls that start the crawl, which may be more than the queue length,
+// and must not be called by the action.
+func (s *State) pushWait(item queuer.Item) error {
+	b, ok := s.Queuer.(queuer.Blocker)
+	if !ok {
+		return s.push(item)
+	}
+	if err := b.PushWait(item); err != nil {
+		s.Logger.Error(item.URL, err)
+		return err
+	}
+	s.Logger.Queued(item.URL)
+	return nil
+}
@@ -1,15 +1,29 @@
 package scraper
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/dave/scrapy/scraper/canonical"
+	"github.com/dave/scrapy/scraper/getter"
 	"github.com/dave/scrapy/scraper/getter/mockgetter"
+	"github.com/dave/scrapy/scraper/graph"
+	"github.com/dave/scrapy/scraper/linkcheck"
 	"github.com/dave/scrapy/scraper/logger/mocklogger"
+	"github.com/dave/scrapy/scraper/parser"
 	"github.com/dave/scrapy/scraper/parser/mockparser"
+	"github.com/dave/scrapy/scraper/queuer"
 	"github.com/dave/scrapy/scraper/queuer/concurrentqueuer"
+	"github.com/dave/scrapy/scraper/queuer/diskqueuer"
+	"github.com/dave/scrapy/scraper/retry"
 )
 
 func TestScraper(t *testing.T) {
@@ -18,10 +32,21 @@ func TestScraper(t *testing.T) {
 		length, workers int
 		timeout         time.Duration
 		start           string
+		seeds           []string
+		maxDepth        *int
 		get             map[string]mockgetter.Dummy
 		parse           map[string]mockparser.Dummy
 		expected        []string
 		cancel          bool
+		sitemaps        bool
+		include         func(*url.URL) bool
+		canonical       *canonical.Rules
+		retry           *retry.Policy
+		maxBody         int64
+		parsers         map[string]mockparser.Dummy
+		edges           []graph.Edge
+		checkExternal   bool
+		broken          string
 	}{
 		{
 			name: "simple",
@@ -39,7 +64,7 @@ func TestScraper(t *testing.T) {
 			parse: map[string]mockparser.Dummy{
 				"a_body": {Urls: []string{"b"}},
 			},
-			expected: []string{"queue a", "start a", "finish a: 200, 1, 0", "queue b", "start b", "finish b: 404, 0, 0"},
+			expected: []string{"queue a", "start a", "finish a: 200, 1, 0", "queue b", "start b (depth 1)", "finish b (depth 1): 404, 0, 0"},
 		},
 		{
 			name:    "queue full",
@@ -51,7 +76,7 @@ func TestScraper(t *testing.T) {
 			parse: map[string]mockparser.Dummy{
 				"a_body": {Urls: []string{"b", "c", "d"}},
 			},
-			expected: []string{"queue a", "start a", "finish a: 200, 3, 0", "queue b", "queue c", "error d: queue full", "start b", "finish b: 404, 0, 0", "start c", "finish c: 404, 0, 0"},
+			expected: []string{"queue a", "start a", "finish a: 200, 3, 0", "queue b", "queue c", "error d: queue full", "start b (depth 1)", "finish b (depth 1): 404, 0, 0", "start c (depth 1)", "finish c (depth 1): 404, 0, 0"},
 		},
 		{
 			name:    "duplicate",
@@ -63,7 +88,7 @@ func TestScraper(t *testing.T) {
 			parse: map[string]mockparser.Dummy{
 				"a_body": {Urls: []string{"b", "b"}},
 			},
-			expected: []string{"queue a", "start a", "finish a: 200, 2, 0", "queue b", "error b: duplicate url", "start b", "finish b: 404, 0, 0"},
+			expected: []string{"queue a", "start a", "finish a: 200, 2, 0", "queue b", "error b: duplicate url", "start b (depth 1)", "finish b (depth 1): 404, 0, 0"},
 		},
 		{
 			name: "complex",
@@ -77,7 +102,320 @@ func TestScraper(t *testing.T) {
 				"a_body": {Urls: []string{"b", "c"}},
 				"c_body": {Urls: []string{"d", "e"}},
 			},
-			expected: []string{"queue a", "start a", "finish a: 200, 2, 0", "queue b", "queue c", "start b", "finish b: 200, 0, 0", "start c", "finish c: 200, 2, 0", "queue d", "queue e", "start d", "finish d: 200, 0, 0", "start e", "finish e: 404, 0, 0"},
+			expected: []string{"queue a", "start a", "finish a: 200, 2, 0", "queue b", "queue c", "start b (depth 1)", "finish b (depth 1): 200, 0, 0", "start c (depth 1)", "finish c (depth 1): 200, 2, 0", "queue d", "queue e", "start d (depth 2)", "finish d (depth 2): 200, 0, 0", "start e (depth 2)", "finish e (depth 2): 404, 0, 0"},
+		},
+		{
+			name: "redirect",
+			get: map[string]mockgetter.Dummy{
+				"a": {Body: "a_body", URL: "c", Redirects: []getter.Redirect{{URL: "a", Code: 301}}},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_body": {Urls: []string{"c", "b"}},
+			},
+			expected: []string{"queue a", "start a", "finish a -> c: 200, 2, 0", "error c: duplicate url", "queue b", "start b (depth 1)", "finish b (depth 1): 404, 0, 0"},
+		},
+		{
+			name: "redirect loop",
+			get: map[string]mockgetter.Dummy{
+				"a": {Err: getter.ErrRedirectLoop, Redirects: []getter.Redirect{{URL: "a", Code: 301}, {URL: "b", Code: 302}}},
+			},
+			expected: []string{"queue a", "start a", "error a (a 301, b 302): redirect loop"},
+		},
+		{
+			name: "graph",
+			get: map[string]mockgetter.Dummy{
+				"a": {Body: "a_body", URL: "c", Redirects: []getter.Redirect{{URL: "a", Code: 301}, {URL: "b", Code: 302}}},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_body": {Urls: []string{"c", "d"}},
+			},
+			expected: []string{"queue a", "start a", "finish a -> c: 200, 2, 0", "error c: duplicate url", "queue d", "start d (depth 1)", "finish d (depth 1): 404, 0, 0"},
+			edges: []graph.Edge{
+				{From: "a", To: "b", Kind: graph.KindRedirect},
+				{From: "b", To: "c", Kind: graph.KindRedirect},
+				{From: "c", To: "c", Kind: parser.KindAnchor},
+				{From: "c", To: "d", Kind: parser.KindAnchor},
+			},
+		},
+		{
+			name: "check links",
+			get: map[string]mockgetter.Dummy{
+				"a":        {Body: "a_body"},
+				"http://x": {Body: "x_body"},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_body": {Urls: []string{"b"}, Excluded: []string{"http://x", "http://y", "mailto:z"}},
+				"x_body": {Urls: []string{"c"}},
+			},
+			checkExternal: true,
+			expected:      []string{"queue a", "start a", "finish a: 200, 1, 0", "queue b", "queue http://x", "queue http://y", "start b (depth 1)", "finish b (depth 1): 404, 0, 0", "start http://x (depth 1)", "finish http://x (depth 1): 200, 0, 0", "start http://y (depth 1)", "finish http://y (depth 1): 404, 0, 0"},
+			broken:        "Broken links: 2\n\nb: response code 404\n  a\n\nhttp://y: response code 404\n  a\n",
+		},
+		{
+			name:  "check links on other hosts",
+			seeds: []string{"http://a/"},
+			get: map[string]mockgetter.Dummy{
+				"http://a/": {Body: "a_body"},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_body": {Excluded: []string{"http://a/private", "http://x/"}},
+			},
+			checkExternal: true,
+			expected:      []string{"queue http://a/", "start http://a/", "finish http://a/: 200, 0, 0", "queue http://x/", "start http://x/ (depth 1)", "finish http://x/ (depth 1): 404, 0, 0"},
+			broken:        "Broken links: 1\n\nhttp://x/: response code 404\n  http://a/\n",
+		},
+		{
+			name:  "seeds",
+			seeds: []string{"a", "b", "a"},
+			get: map[string]mockgetter.Dummy{
+				"a": {Body: "a_body"},
+				"b": {Body: "b_body"},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_body": {Urls: []string{"b", "c"}},
+			},
+			expected: []string{"queue a", "queue b", "error a: duplicate url", "start a", "finish a: 200, 2, 0", "error b: duplicate url", "queue c", "start b", "finish b: 200, 0, 0", "start c (depth 1)", "finish c (depth 1): 404, 0, 0"},
+		},
+		{
+			name:   "seeds longer than the queue",
+			length: 1,
+			seeds:  []string{"a", "b", "c"},
+			get: map[string]mockgetter.Dummy{
+				"a": {Body: "a_body"},
+				"b": {Body: "b_body"},
+				"c": {Body: "c_body"},
+			},
+			expected: []string{"queue a", "queue b", "queue c", "start a", "finish a: 200, 0, 0", "start b", "finish b: 200, 0, 0", "start c", "finish c: 200, 0, 0"},
+		},
+		{
+			name: "redirect to seen url",
+			get: map[string]mockgetter.Dummy{
+				"a": {Body: "a_body"},
+				"b": {Body: "c_body", URL: "c", Redirects: []getter.Redirect{{URL: "b", Code: 302}}},
+				"c": {Body: "c_body"},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_body": {Urls: []string{"b", "c"}},
+				"c_body": {Urls: []string{"d"}},
+			},
+			expected: []string{"queue a", "start a", "finish a: 200, 2, 0", "queue b", "queue c", "start b (depth 1)", "finish b (depth 1) -> c: 200, 0, 0", "start c (depth 1)", "finish c (depth 1): 200, 1, 0", "queue d", "start d (depth 2)", "finish d (depth 2): 404, 0, 0"},
+		},
+		{
+			name:     "seeds only",
+			maxDepth: depth(0),
+			get: map[string]mockgetter.Dummy{
+				"a": {Body: "a_body"},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_body": {Urls: []string{"b"}},
+			},
+			expected: []string{"queue a", "start a", "finish a: 200, 1, 0"},
+		},
+		{
+			name:     "max depth",
+			maxDepth: depth(1),
+			get: map[string]mockgetter.Dummy{
+				"a": {Body: "a_body"},
+				"b": {Body: "b_body"},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_body": {Urls: []string{"b"}},
+				"b_body": {Urls: []string{"c"}},
+			},
+			expected: []string{"queue a", "start a", "finish a: 200, 1, 0", "queue b", "start b (depth 1)", "finish b (depth 1): 200, 1, 0"},
+		},
+		{
+			name:     "sitemaps",
+			start:    "https://a/",
+			sitemaps: true,
+			get: map[string]mockgetter.Dummy{
+				"https://a/robots.txt": {Body: "Sitemap: https://a/index.xml"},
+				"https://a/index.xml":  {Body: `<sitemapindex><sitemap><loc>https://a/pages.xml</loc></sitemap></sitemapindex>`},
+				"https://a/pages.xml":  {Body: `<urlset><url><loc>https://a/</loc></url><url><loc>https://a/b</loc></url><url><loc>https://a/c</loc></url></urlset>`},
+				"https://a/":           {Body: "a_body"},
+				"https://a/b":          {Body: "b_body"},
+				"https://a/c":          {Body: "c_body"},
+				"https://a/d":          {Body: "d_body"},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_body": {Urls: []string{"https://a/b", "https://a/d"}},
+			},
+			expected: []string{
+				"queue https://a/",
+				"error https://a/: duplicate url",
+				"queue https://a/b",
+				"queue https://a/c",
+				"start https://a/",
+				"finish https://a/: 200, 2, 0",
+				"error https://a/b: duplicate url",
+				"queue https://a/d",
+				"start https://a/b",
+				"finish https://a/b: 200, 0, 0",
+				"start https://a/c",
+				"finish https://a/c: 200, 0, 0",
+				"start https://a/d (depth 1)",
+				"finish https://a/d (depth 1): 200, 0, 0",
+				"sitemap orphans: https://a/c; missing: https://a/d",
+			},
+		},
+		{
+			name:     "sitemaps longer than the queue",
+			start:    "https://a/",
+			sitemaps: true,
+			length:   2,
+			get: map[string]mockgetter.Dummy{
+				"https://a/sitemap.xml": {Body: `<urlset><url><loc>https://a/p1</loc></url><url><loc>https://a/p2</loc></url><url><loc>https://a/p3</loc></url><url><loc>https://a/p4</loc></url><url><loc>https://a/p5</loc></url></urlset>`},
+				"https://a/":            {Body: "a_body"},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_body": {Urls: []string{"https://a/p1"}},
+			},
+			expected: []string{
+				"queue https://a/",
+				"queue https://a/p1",
+				"queue https://a/p2",
+				"queue https://a/p3",
+				"queue https://a/p4",
+				"queue https://a/p5",
+				"start https://a/",
+				"finish https://a/: 200, 1, 0",
+				"error https://a/p1: duplicate url",
+				"start https://a/p1",
+				"finish https://a/p1: 404, 0, 0",
+				"start https://a/p2",
+				"finish https://a/p2: 404, 0, 0",
+				"start https://a/p3",
+				"finish https://a/p3: 404, 0, 0",
+				"start https://a/p4",
+				"finish https://a/p4: 404, 0, 0",
+				"start https://a/p5",
+				"finish https://a/p5: 404, 0, 0",
+				"sitemap orphans: https://a/p2, https://a/p3, https://a/p4, https://a/p5; missing: https://a/",
+			},
+		},
+		{
+			name:      "sitemaps canonical",
+			start:     "https://a/",
+			sitemaps:  true,
+			canonical: &canonical.Rules{TrailingSlash: canonical.StripSlash},
+			get: map[string]mockgetter.Dummy{
+				"https://a/sitemap.xml": {Body: `<urlset><url><loc>https://a/</loc></url><url><loc>https://a/b</loc></url></urlset>`},
+				"https://a/":            {Body: "a_body"},
+				"https://a/b":           {Body: "b_body"},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_body": {Urls: []string{"https://a/b/"}},
+				"b_body": {Urls: []string{"https://a/"}},
+			},
+			expected: []string{
+				"queue https://a/",
+				"error https://a/: duplicate url",
+				"queue https://a/b",
+				"start https://a/",
+				"finish https://a/: 200, 1, 0",
+				"error https://a/b/: duplicate url",
+				"start https://a/b",
+				"finish https://a/b: 200, 1, 0",
+				"error https://a/: duplicate url",
+				"sitemap orphans: ; missing: ",
+			},
+		},
+		{
+			name:     "sitemaps scope",
+			start:    "https://a/",
+			sitemaps: true,
+			include:  func(u *url.URL) bool { return u.Host == "a" },
+			get: map[string]mockgetter.Dummy{
+				"https://a/robots.txt":  {Body: "Sitemap: https://x/sitemap.xml"},
+				"https://x/sitemap.xml": {Body: `<urlset><url><loc>https://x/y</loc></url></urlset>`},
+				"https://a/sitemap.xml": {Body: `<urlset><url><loc>https://a/</loc></url><url><loc>https://x/z</loc></url></urlset>`},
+				"https://a/":            {Body: "a_body"},
+			},
+			expected: []string{
+				"queue https://a/",
+				"error https://a/: duplicate url",
+				"start https://a/",
+				"finish https://a/: 200, 0, 0",
+				"sitemap orphans: ; missing: ",
+			},
+		},
+		{
+			name:     "skipped",
+			start:    "https://a/",
+			sitemaps: true,
+			get: map[string]mockgetter.Dummy{
+				"https://a/sitemap.xml": {Body: `<urlset><url><loc>https://a/</loc></url></urlset>`},
+				"https://a/":            {Body: "a_body"},
+				"https://a/b":           {Body: "a_body", Skipped: true},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_body": {Urls: []string{"https://a/b"}},
+			},
+			expected: []string{
+				"queue https://a/",
+				"error https://a/: duplicate url",
+				"start https://a/",
+				"finish https://a/: 200, 1, 0",
+				"queue https://a/b",
+				"start https://a/b (depth 1)",
+				"finish https://a/b (depth 1): 200, 0, 0 (skipped)",
+				"sitemap orphans: ; missing: ",
+			},
+		},
+		{
+			name:  "retry",
+			retry: &retry.Policy{Attempts: 3, Backoff: time.Millisecond},
+			get: map[string]mockgetter.Dummy{
+				"a": {Body: "a_body", Before: []mockgetter.Dummy{{Code: 503}, {Err: errors.New("connection reset")}}},
+			},
+			parse:    map[string]mockparser.Dummy{},
+			expected: []string{"queue a", "start a", "retry a (attempt 1, 1ms): response code 503", "retry a (attempt 2, 2ms): connection reset", "finish a: 200, 0, 0"},
+		},
+		{
+			name:  "retry gives up",
+			retry: &retry.Policy{Attempts: 2, Backoff: time.Millisecond},
+			get: map[string]mockgetter.Dummy{
+				"a": {Code: 503},
+			},
+			parse:    map[string]mockparser.Dummy{},
+			expected: []string{"queue a", "start a", "retry a (attempt 1, 1ms): response code 503", "finish a: 503, 0, 0"},
+		},
+		{
+			name:    "retry timeout",
+			timeout: time.Millisecond * 10,
+			retry:   &retry.Policy{Attempts: 2, Backoff: time.Millisecond},
+			get: map[string]mockgetter.Dummy{
+				"a": {Body: "a_body", Before: []mockgetter.Dummy{{Latency: time.Second}}},
+			},
+			parse:    map[string]mockparser.Dummy{},
+			expected: []string{"queue a", "start a", "retry a (attempt 1, 1ms): context deadline exceeded", "finish a: 200, 0, 0"},
+		},
+		{
+			name:    "truncated",
+			maxBody: 3,
+			get: map[string]mockgetter.Dummy{
+				"a": {Body: "a_body"},
+				"b": {Body: "b"},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_b": {Urls: []string{"b"}},
+			},
+			expected: []string{"queue a", "start a", "finish a: 200, 1, 0 (truncated)", "queue b", "start b (depth 1)", "finish b (depth 1): 200, 0, 0"},
+		},
+		{
+			name: "media types",
+			get: map[string]mockgetter.Dummy{
+				"a": {Body: "a_body"},
+				"b": {Body: "b_body", MediaType: "text/css"},
+				"c": {Body: "c_body", MediaType: "image/png"},
+			},
+			parse: map[string]mockparser.Dummy{
+				"a_body": {Urls: []string{"b", "c"}},
+			},
+			parsers: map[string]mockparser.Dummy{
+				"b_body": {Urls: []string{"d"}},
+			},
+			expected: []string{"queue a", "start a", "finish a: 200, 2, 0", "queue b", "queue c", "start b (depth 1)", "finish b (depth 1): 200, 1, 0", "queue d", "start c (depth 1)", "finish c (depth 1): 200, 0, 0", "start d (depth 2)", "finish d (depth 2): 404, 0, 0"},
 		},
 		{
 			name:    "timeout",
@@ -117,9 +455,12 @@ func TestScraper(t *testing.T) {
 				length = test.length
 			}
 
-			start := "a"
+			seeds := []string{"a"}
 			if test.start != "" {
-				start = test.start
+				seeds = []string{test.start}
+			}
+			if test.seeds != nil {
+				seeds = test.seeds
 			}
 
 			ctx := context.Background()
@@ -129,19 +470,98 @@ func TestScraper(t *testing.T) {
 				cancel()
 			}
 
+			var g *graph.Graph
+			if test.edges != nil {
+				g = &graph.Graph{}
+			}
+
+			var checker *linkcheck.Checker
+			if test.broken != "" {
+				checker = &linkcheck.Checker{}
+			}
+
 			state := &State{
-				Timeout: timeout,
-				Getter:  &mockgetter.Getter{Results: test.get},
-				Parser:  &mockparser.Parser{Results: test.parse},
-				Queuer:  &concurrentqueuer.Queuer{Length: length, Workers: workers},
-				Logger:  log,
+				Timeout:   timeout,
+				MaxDepth:  test.maxDepth,
+				Sitemaps:  test.sitemaps,
+				Include:   test.include,
+				Canonical: test.canonical,
+				Retry:     test.retry,
+				Getter:    &mockgetter.Getter{Results: test.get, MaxBodyBytes: test.maxBody},
+				Parser:    &mockparser.Parser{Results: test.parse},
+				Parsers:   map[string]parser.Interface{"text/css": &mockparser.Parser{Results: test.parsers}},
+				Queuer:    &concurrentqueuer.Queuer{Length: length, Workers: workers, Canonical: test.canonical},
+				Logger:    log,
+				Graph:     g,
+				Checker:   checker,
+
+				CheckExternal: test.checkExternal,
 			}
 
-			state.Start(ctx, start)
+			state.Start(ctx, seeds...)
 
 			if !reflect.DeepEqual(log.Log, test.expected) {
 				t.Errorf("unexpected log contents - found %#v", log.Log)
 			}
+			if g != nil && !reflect.DeepEqual(g.Edges(), test.edges) {
+				t.Errorf("unexpected edges - found %#v", g.Edges())
+			}
+			if checker != nil {
+				var b bytes.Buffer
+				checker.WriteReport(&b)
+				if b.String() != test.broken {
+					t.Errorf("unexpected broken links - found %q", b.String())
+				}
+			}
 		})
 	}
 }
+
+// TestScraper_resume tests that each pending url is queued once when a crawl is resumed, including pending seed urls
+func TestScraper_resume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scraper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "frontier")
+
+	// The previous run was stopped before a or b were processed
+	q, err := diskqueuer.Open(path, &concurrentqueuer.Queuer{Length: 10, Workers: 1}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{"a", "b"} {
+		if err := q.Push(queuer.Item{URL: u}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err = diskqueuer.Open(path, &concurrentqueuer.Queuer{Length: 1, Workers: 1}, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	log := &mocklogger.Logger{}
+	state := &State{
+		Timeout: time.Second,
+		Getter:  &mockgetter.Getter{Results: map[string]mockgetter.Dummy{"a": {Body: "a_body"}, "b": {Body: "b_body"}}},
+		Parser:  &mockparser.Parser{},
+		Queuer:  q,
+		Logger:  log,
+	}
+	state.Start(context.Background(), "a")
+
+	expected := []string{"queue a", "error a: duplicate url", "queue b", "start a", "finish a: 200, 0, 0", "start b", "finish b: 200, 0, 0"}
+	if !reflect.DeepEqual(log.Log, expected) {
+		t.Errorf("unexpected log contents - found %#v", log.Log)
+	}
+}
+
+// depth returns a pointer to a max depth
+func depth(n int) *int {
+	return &n
+}
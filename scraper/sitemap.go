@@ -0,0 +1,150 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+
+	"github.com/dave/scrapy/scraper/getter"
+	"github.com/dave/scrapy/scraper/robots"
+	"github.com/dave/scrapy/scraper/sitemap"
+)
+
+// maxSitemaps limits the number of sitemap files fetched, in case sitemap index files refer to each other
+const maxSitemaps = 1000
+
+// siteRoots returns the first seed url for each site (scheme and host), so each site's sitemaps are only fetched once
+func siteRoots(urls []string) (roots []string) {
+	seen := map[string]bool{}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		site := u.Scheme + "://" + u.Host
+		if seen[site] {
+			continue
+		}
+		seen[site] = true
+		roots = append(roots, raw)
+	}
+	return roots
+}
+
+// sitemapURLs fetches the sitemaps listed in robots.txt and /sitemap.xml for the host of the start url, and returns the
+// page urls they list
+func (s *State) sitemapURLs(ctx context.Context, start string) (urls []string) {
+	u, err := url.Parse(start)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	root := u.Scheme + "://" + u.Host
+
+	// The sitemaps listed in robots.txt are fetched first, then /sitemap.xml
+	var queue []string
+	s.fetch(ctx, root+"/robots.txt", false, func(r io.Reader) error {
+		data, err := robots.Parse(r)
+		if err != nil {
+			return err
+		}
+		queue = append(queue, data.Sitemaps...)
+		return nil
+	})
+	queue = append(queue, root+"/sitemap.xml")
+
+	fetched := map[string]bool{}
+	found := map[string]bool{}
+	for len(queue) > 0 && len(fetched) < maxSitemaps {
+		sm := queue[0]
+		queue = queue[1:]
+		if fetched[sm] || !s.sitemapAllowed(u, sm) {
+			continue
+		}
+		fetched[sm] = true
+
+		// The default location often doesn't exist, so a missing /sitemap.xml isn't an error
+		required := sm != root+"/sitemap.xml"
+
+		s.fetch(ctx, sm, required, func(r io.Reader) error {
+			// The urls found before a parse error are still used
+			data, err := sitemap.Parse(r)
+			if data == nil {
+				return err
+			}
+			// Sitemap index files list more sitemaps
+			queue = append(queue, data.Sitemaps...)
+			for _, raw := range data.URLs {
+				listed, err := url.Parse(raw)
+				if err != nil || s.Include != nil && !s.Include(listed) {
+					continue
+				}
+				c := s.Canonical.String(raw)
+				if !found[c] {
+					found[c] = true
+					urls = append(urls, c)
+				}
+			}
+			return err
+		})
+	}
+	return urls
+}
+
+// sitemapAllowed returns true if a sitemap file should be fetched: it is on the host of the start url, or is accepted by
+// the include function
+func (s *State) sitemapAllowed(start *url.URL, sitemap string) bool {
+	u, err := url.Parse(sitemap)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == start.Scheme && u.Host == start.Host {
+		return true
+	}
+	return s.Include == nil || s.Include(u)
+}
+
+// fetch gets a url and calls parse with the body. Errors are logged, and if required is true a response code other
+// than 200 is also logged as an error.
+func (s *State) fetch(ctx context.Context, url string, required bool, parse func(io.Reader) error) {
+	_, r, cancel := s.get(ctx, url, getter.Options{})
+	defer cancel()
+
+	if r.Err != nil {
+		s.Logger.Error(url, r.Err)
+		return
+	}
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+	if r.Code != 200 {
+		if required {
+			s.Logger.Error(url, fmt.Errorf("response code %d", r.Code))
+		}
+		return
+	}
+	if err := parse(r.Body); err != nil {
+		s.Logger.Error(url, err)
+	}
+}
+
+// sitemapReport returns the sitemap urls that were never linked (orphans) and the crawled pages that were missing from
+// the sitemap, sorted
+func (s *State) sitemapReport() (orphans, missing []string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for u := range s.inSitemap {
+		if !s.linked[u] {
+			orphans = append(orphans, u)
+		}
+	}
+	for u := range s.crawled {
+		if !s.inSitemap[u] {
+			missing = append(missing, u)
+		}
+	}
+	sort.Strings(orphans)
+	sort.Strings(missing)
+	return orphans, missing
+}
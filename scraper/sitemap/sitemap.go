@@ -0,0 +1,97 @@
+// Package sitemap parses sitemap.xml and sitemap index files, which may be gzip compressed
+package sitemap
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// MaxSize is the max uncompressed size of a sitemap file (the limit in the sitemaps protocol is 50MB)
+const MaxSize = 50 << 20
+
+// Data is a parsed sitemap or sitemap index file
+type Data struct {
+	URLs     []string // Page urls listed in a <urlset>
+	Sitemaps []string // Sitemap urls listed in a <sitemapindex>
+}
+
+// NotSitemapError is returned by Parse when the root element isn't <urlset> or <sitemapindex>
+type NotSitemapError struct {
+	Root string // The name of the root element
+}
+
+func (e *NotSitemapError) Error() string {
+	return fmt.Sprintf("not a sitemap: <%s>", e.Root)
+}
+
+// Parse parses a sitemap or sitemap index file. Gzip compressed files are detected and decompressed. If there is a
+// parse error after the root element, the urls found before the error are returned with it.
+func Parse(r io.Reader) (*Data, error) {
+	br := bufio.NewReader(r)
+
+	// Gzip files start with the magic number 0x1f 0x8b
+	var in io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		in = gz
+	}
+
+	dec := xml.NewDecoder(io.LimitReader(in, MaxSize))
+	dec.Strict = false
+	dec.CharsetReader = charset.NewReaderLabel
+
+	var d *Data
+	var stack []string // The names of the open elements
+	var loc string     // The text of the current <loc> element
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF && d != nil {
+			return d, nil
+		}
+		if err != nil {
+			return d, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if d == nil {
+				// The root element decides if the document is a sitemap
+				if tok.Name.Local != "urlset" && tok.Name.Local != "sitemapindex" {
+					return nil, &NotSitemapError{Root: tok.Name.Local}
+				}
+				d = &Data{}
+			}
+			stack = append(stack, tok.Name.Local)
+			loc = ""
+		case xml.CharData:
+			if len(stack) > 0 && stack[len(stack)-1] == "loc" {
+				loc += string(tok)
+			}
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			// Page urls are in <url><loc>, and sitemap urls are in <sitemap><loc>
+			if len(stack) > 1 && stack[len(stack)-1] == "loc" {
+				if l := strings.TrimSpace(loc); l != "" {
+					switch stack[len(stack)-2] {
+					case "url":
+						d.URLs = append(d.URLs, l)
+					case "sitemap":
+						d.Sitemaps = append(d.Sitemaps, l)
+					}
+				}
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
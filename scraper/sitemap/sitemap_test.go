@@ -0,0 +1,81 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const urlset = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>https://a/</loc><lastmod>2018-01-01</lastmod></url>
+	<url><loc>
+		https://a/b
+	</loc></url>
+	<url><loc></loc></url>
+</urlset>`
+
+const index = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>https://a/1.xml</loc></sitemap>
+	<sitemap><loc>https://a/2.xml.gz</loc></sitemap>
+</sitemapindex>`
+
+func TestParse(t *testing.T) {
+	gz := &bytes.Buffer{}
+	w := gzip.NewWriter(gz)
+	w.Write([]byte(urlset))
+	w.Close()
+
+	tests := []struct {
+		name     string
+		body     string
+		expected *Data
+		err      string
+	}{
+		{
+			name:     "urlset",
+			body:     urlset,
+			expected: &Data{URLs: []string{"https://a/", "https://a/b"}},
+		},
+		{
+			name:     "index",
+			body:     index,
+			expected: &Data{Sitemaps: []string{"https://a/1.xml", "https://a/2.xml.gz"}},
+		},
+		{
+			name:     "gzip",
+			body:     gz.String(),
+			expected: &Data{URLs: []string{"https://a/", "https://a/b"}},
+		},
+		{
+			name: "not a sitemap",
+			body: `<html><body></body></html>`,
+			err:  "not a sitemap: <html>",
+		},
+		{
+			name: "empty",
+			body: ``,
+			err:  "EOF",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := Parse(strings.NewReader(test.body))
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("expected error %s, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(d, test.expected) {
+				t.Errorf("unexpected data %#v", d)
+			}
+		})
+	}
+}